@@ -11,6 +11,223 @@ import (
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
 )
 
+// Política de reemplazo de la caché: con la caché llena elige el índice de la entrada a reemplazar.
+// registrarCarga se llama con cada página que entra, esté llena o no
+type AlgoritmoReemplazo interface {
+	elegirVictima(entradas []globalsCpu.EntradaCache) int
+	registrarCarga(nueva *globalsCpu.EntradaCache)
+}
+
+// Para los algoritmos a los que no les importa qué página entra
+type sinRegistro struct {
+}
+
+func (s sinRegistro) registrarCarga(nueva *globalsCpu.EntradaCache) {
+}
+
+type ClockReemplazo struct {
+	sinRegistro
+}
+
+func (c ClockReemplazo) elegirVictima(entradas []globalsCpu.EntradaCache) int {
+	return victimaClock()
+}
+
+type ClockMReemplazo struct {
+	sinRegistro
+}
+
+func (c ClockMReemplazo) elegirVictima(entradas []globalsCpu.EntradaCache) int {
+	return victimaClockM()
+}
+
+type LRUReemplazo struct {
+	sinRegistro
+}
+
+func (l LRUReemplazo) elegirVictima(entradas []globalsCpu.EntradaCache) int {
+	return menosRecienteUsada(entradas, func(entrada globalsCpu.EntradaCache) bool { return true })
+}
+
+// La entrada con menos accesos desde que se cargó. Entre las empatadas, la más vieja
+type LFUReemplazo struct {
+	sinRegistro
+}
+
+func (l LFUReemplazo) elegirVictima(entradas []globalsCpu.EntradaCache) int {
+	victima := 0
+	for i := 1; i < len(entradas); i++ {
+		menosAccesos := entradas[i].Accesos < entradas[victima].Accesos
+		empate := entradas[i].Accesos == entradas[victima].Accesos
+		if menosAccesos || (empate && entradas[i].InstanteCargado.Before(entradas[victima].InstanteCargado)) {
+			victima = i
+		}
+	}
+	return victima
+}
+
+// Página que salió de la cola de recientes del 2Q
+type paginaCache struct {
+	pid    int
+	pagina int
+}
+
+// 2Q: las páginas nuevas entran a una cola FIFO de recientes (A1in) y las que salen de ahí se
+// recuerdan en un historial (A1out). Si una página del historial se vuelve a pedir entra como
+// frecuente (Am), que se reemplaza por LRU. Así un recorrido de una sola pasada no desplaza a
+// las páginas que se usan seguido
+type DosQReemplazo struct {
+	maxRecientes int // Kin: con más recientes que esto la víctima sale de A1in
+	maxHistorial int // Kout: páginas recordadas en A1out
+	historial    []paginaCache
+}
+
+func (d *DosQReemplazo) registrarCarga(nueva *globalsCpu.EntradaCache) {
+	for i, pagina := range d.historial {
+		if pagina.pid == nueva.Pid && pagina.pagina == nueva.Pagina {
+			d.historial = append(d.historial[:i], d.historial[i+1:]...)
+			nueva.Frecuente = true
+			return
+		}
+	}
+}
+
+func (d *DosQReemplazo) elegirVictima(entradas []globalsCpu.EntradaCache) int {
+	recientes := 0
+	for _, entrada := range entradas {
+		if !entrada.Frecuente {
+			recientes++
+		}
+	}
+
+	if recientes > d.maxRecientes || recientes == len(entradas) {
+		victima := -1
+		for i, entrada := range entradas {
+			if !entrada.Frecuente && (victima < 0 || entrada.InstanteCargado.Before(entradas[victima].InstanteCargado)) {
+				victima = i
+			}
+		}
+		d.historial = append(d.historial, paginaCache{entradas[victima].Pid, entradas[victima].Pagina})
+		if len(d.historial) > d.maxHistorial {
+			d.historial = d.historial[1:]
+		}
+		return victima
+	}
+	return menosRecienteUsada(entradas, func(entrada globalsCpu.EntradaCache) bool { return entrada.Frecuente })
+}
+
+// Índice de la entrada usada hace más tiempo entre las que cumplen el filtro (al menos una tiene que cumplirlo)
+func menosRecienteUsada(entradas []globalsCpu.EntradaCache, filtro func(globalsCpu.EntradaCache) bool) int {
+	victima := -1
+	for i, entrada := range entradas {
+		if filtro(entrada) && (victima < 0 || entrada.UltimoUso.Before(entradas[victima].UltimoUso)) {
+			victima = i
+		}
+	}
+	return victima
+}
+
+var algoritmo AlgoritmoReemplazo = ClockReemplazo{}
+
+// Contadores de la caché, protegidos por CacheMutex
+var hits, misses, reemplazos, desalojosModificados, escrituras, escriturasAMemoria int
+
+// Elige el algoritmo según cache_replacement y valida cache_write_policy al arrancar
+func IniciarCache() {
+	config := globalsCpu.CpuConfig
+	switch strings.ToUpper(config.CacheReplacment) {
+	case "CLOCK":
+		algoritmo = ClockReemplazo{}
+	case "CLOCK-M":
+		algoritmo = ClockMReemplazo{}
+	case "LRU":
+		algoritmo = LRUReemplazo{}
+	case "LFU":
+		algoritmo = LFUReemplazo{}
+	case "2Q":
+		// Proporciones de la propuesta original del 2Q: un cuarto para recientes, historial de la mitad
+		algoritmo = &DosQReemplazo{
+			maxRecientes: max(1, config.CacheEntries/4),
+			maxHistorial: max(1, config.CacheEntries/2),
+		}
+	default:
+		if config.CacheEntries != 0 {
+			panic(fmt.Sprintf("cache_replacement desconocido: %s", config.CacheReplacment))
+		}
+	}
+
+	switch strings.ToUpper(config.CacheWritePolicy) {
+	case "", "WRITE_BACK", "WRITE_THROUGH":
+	default:
+		if globalsCpu.CpuConfig.CacheEntries != 0 {
+			panic(fmt.Sprintf("cache_write_policy desconocida: %s", globalsCpu.CpuConfig.CacheWritePolicy))
+		}
+	}
+}
+
+// Con write-through cada escritura llega a Memoria en el momento y la caché nunca queda modificada
+func EscrituraInmediata() bool {
+	return strings.ToUpper(globalsCpu.CpuConfig.CacheWritePolicy) == "WRITE_THROUGH"
+}
+
+func AsignaEnEscritura() bool {
+	return !globalsCpu.CpuConfig.CacheNoWriteAllocate
+}
+
+// Cuenta una escritura del proceso con la caché activa y si se mandó en el momento a Memoria
+func RegistrarEscritura(aMemoria bool) {
+	globalsCpu.CacheMutex.Lock()
+	defer globalsCpu.CacheMutex.Unlock()
+	escrituras++
+	if aMemoria {
+		escriturasAMemoria++
+	}
+}
+
+// Contadores de la caché desde que arrancó la CPU, para comparar algoritmos y políticas sobre un mismo
+// script. Ahorradas compara contra mandar cada escritura a Memoria: es negativo si la política bajó
+// más páginas que escrituras hubo
+type MetricasCache struct {
+	Algoritmo            string  `json:"algoritmo"`
+	Entradas             int     `json:"entradas"`
+	Hits                 int     `json:"hits"`
+	Misses               int     `json:"misses"`
+	Reemplazos           int     `json:"reemplazos"`
+	DesalojosModificados int     `json:"desalojos_modificados"` // reemplazos que bajaron la página a Memoria
+	TasaHits             float64 `json:"tasa_hits"`
+	PoliticaEscritura    string  `json:"politica_escritura"`
+	AsignaEnEscritura    bool    `json:"asigna_en_escritura"`
+	Escrituras           int     `json:"escrituras"`
+	EscriturasAMemoria   int     `json:"escrituras_a_memoria"`
+	Ahorradas            int     `json:"escrituras_ahorradas"`
+}
+
+func Metricas() MetricasCache {
+	globalsCpu.CacheMutex.Lock()
+	defer globalsCpu.CacheMutex.Unlock()
+	politica := "WRITE_BACK"
+	if EscrituraInmediata() {
+		politica = "WRITE_THROUGH"
+	}
+	metricas := MetricasCache{
+		Algoritmo:            globalsCpu.CpuConfig.CacheReplacment,
+		Entradas:             globalsCpu.CpuConfig.CacheEntries,
+		Hits:                 hits,
+		Misses:               misses,
+		Reemplazos:           reemplazos,
+		DesalojosModificados: desalojosModificados,
+		PoliticaEscritura:    politica,
+		AsignaEnEscritura:    AsignaEnEscritura(),
+		Escrituras:           escrituras,
+		EscriturasAMemoria:   escriturasAMemoria,
+		Ahorradas:            escrituras - escriturasAMemoria,
+	}
+	if consultas := hits + misses; consultas > 0 {
+		metricas.TasaHits = float64(hits) / float64(consultas)
+	}
+	return metricas
+}
+
 func BuscarPaginaEnCache(pid int, pagina int) ([]byte, bool) {
 	globalsCpu.CacheMutex.Lock()
 	defer globalsCpu.CacheMutex.Unlock()
@@ -20,17 +237,36 @@ func BuscarPaginaEnCache(pid int, pagina int) ([]byte, bool) {
 		if entrada.Pid == pid && entrada.Pagina == pagina {
 			//CACHE DELAY
 			globalsCpu.Cache[i].Uso = true
+			globalsCpu.Cache[i].UltimoUso = time.Now()
+			globalsCpu.Cache[i].Accesos++
+			hits++
 			time.Sleep(time.Duration(globalsCpu.CpuConfig.CacheDelay))
 			clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Cache HIT - Pagina: %d", pid, pagina))
 			return entrada.Contenido, true
 		}
 	}
 
+	misses++
 	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Cache MISS - Pagina: %d", pid, pagina))
 	return nil, false
 }
 
-func ModificarContenidoCache(pid int, pagina int, contenido string, direccionLogica int) error {
+// Contenido de una página que se acaba de traer a la caché, sin contarlo como otro acceso
+func ContenidoCargado(pid int, pagina int) ([]byte, bool) {
+	globalsCpu.CacheMutex.Lock()
+	defer globalsCpu.CacheMutex.Unlock()
+
+	for _, entrada := range globalsCpu.Cache {
+		if entrada.Pid == pid && entrada.Pagina == pagina {
+			return entrada.Contenido, true
+		}
+	}
+	return nil, false
+}
+
+// Sin modifica la entrada queda limpia: la escritura ya se manda a Memoria (write-through). El marco
+// es el de la traducción para escribir, que en una página copy-on-write no es el que se leyó
+func ModificarContenidoCache(pid int, pagina int, contenido string, direccionLogica int, marco int, modifica bool) error {
 
 	//contenidoPagina, _ := BuscarPaginaEnCache(pid, pagina)
 
@@ -50,7 +286,11 @@ func ModificarContenidoCache(pid int, pagina int, contenido string, direccionLog
 				globalsCpu.Cache[i].Contenido[desplazamiento] = []byte(contenido)[j]
 			}
 			globalsCpu.Cache[i].Uso = true
-			globalsCpu.Cache[i].Modificado = true
+			globalsCpu.Cache[i].UltimoUso = time.Now()
+			if modifica {
+				globalsCpu.Cache[i].Modificado = true
+				globalsCpu.Cache[i].Marco = marco
+			}
 			//CACHE DELAY
 			time.Sleep(time.Duration(globalsCpu.CpuConfig.CacheDelay))
 			//clientUtils.Logger.Info(fmt.Sprintf("Cache Modify - PID %d Página %d", pid, pagina))
@@ -77,9 +317,14 @@ func buscarEspacioLibrePagina(pid int, contenido []byte) int {
 	return espacioLibre
 }
 
-func AgregarACache(pid int, direccionLogica int, dato []byte, modifica bool) {
+// Trae a la caché la página del marco que ya tradujo quien la pide, con dato copiado en la dirección.
+// dato no pasa de la página. Una escritura pasa el marco de su traducción para escribir: es donde
+// se va a bajar la entrada. Devuelve false si la página no quedó en la caché porque no se pudo leer
+// o porque no se pudo bajar la entrada a reemplazar. La escritura o la lectura tienen que ir
+// directo a Memoria
+func AgregarACache(pid int, direccionLogica int, marco int, dato []byte, modifica bool) bool {
 	if dato == nil {
-		return
+		return false
 	}
 
 	globalsCpu.CacheMutex.Lock()
@@ -88,69 +333,59 @@ func AgregarACache(pid int, direccionLogica int, dato []byte, modifica bool) {
 	pagina := mmuUtils.ObtenerNumeroDePagina(direccionLogica)
 	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
 	tamPagina := globalsCpu.Memoria.TamanioPagina
-	bytesACopiar := min(len(dato), tamPagina-desplazamiento)
-	restante := dato[bytesACopiar:]
-	sePasa := desplazamiento+len(dato) > tamPagina
+	if desplazamiento+len(dato) > tamPagina {
+		clientUtils.Logger.Error("AgregarACache - El contenido pasa a la página siguiente")
+		return false
+	}
 
 	// Delay de caché
 	time.Sleep(time.Millisecond * time.Duration(globalsCpu.CpuConfig.CacheDelay))
 
 	// Leer el contenido completo de la página actual desde Memoria
-	marco, err := mmuUtils.ObtenerMarco(pid, direccionLogica)
-	if err != nil {
-		clientUtils.Logger.Error("AgregarACache - No se pudo obtener el marco antes de leer la página")
-		return
-	}
-
 	paginaCompleta, err := consultaRead(pid, marco)
 
 	if err != nil || len(paginaCompleta) != tamPagina {
 		clientUtils.Logger.Error("AgregarACache - No se pudo leer la página completa antes de escribir en caché")
-		return
+		return false
 	}
 
 	// Reemplazar parte de la página con el nuevo contenido
-	copy(paginaCompleta[desplazamiento:], dato[:bytesACopiar])
+	copy(paginaCompleta[desplazamiento:], dato)
 
 	nuevaEntrada := globalsCpu.EntradaCache{
-		Pid:        pid,
-		Pagina:     pagina,
-		Contenido:  paginaCompleta,
-		Uso:        true,
-		Modificado: modifica,
+		Pid:             pid,
+		Pagina:          pagina,
+		Contenido:       paginaCompleta,
+		Uso:             true,
+		Modificado:      modifica,
+		Marco:           marco,
+		UltimoUso:       time.Now(),
+		InstanteCargado: time.Now(),
 	}
+	algoritmo.registrarCarga(&nuevaEntrada)
 
 	if len(globalsCpu.Cache) < globalsCpu.CpuConfig.CacheEntries {
 		globalsCpu.Cache = append(globalsCpu.Cache, nuevaEntrada)
 		clientUtils.Logger.Info(fmt.Sprintf("PID %d - Cache Add - Página %d", pid, pagina))
-	} else {
-		/*
-			for i := range globalsCpu.CpuConfig.CacheEntries {
-				clientUtils.Logger.Debug("Paginas en cache con uso y modificado", "Pagina", globalsCpu.Cache[i].Pagina, "Uso", globalsCpu.Cache[i].Uso, "Modificado", globalsCpu.Cache[i].Modificado)
-			}*/
-		//clientUtils.Logger.Debug("Puntero en : ", "Puntero", globalsCpu.PunteroClock)
-		switch strings.ToLower(globalsCpu.CpuConfig.CacheReplacment) {
-		case "clock":
-			reemplazarPorClock(nuevaEntrada)
-		case "clock-m":
-			reemplazarPorClockM(nuevaEntrada, pid, direccionLogica)
-		default:
-			clientUtils.Logger.Error("Algoritmo de reemplazo de cache no reconocido")
-		}
-	}
-
-	// Si el contenido se desbordó a otra página, escribimos el resto recursivamente
-	if sePasa {
-		nuevaDireccion := direccionLogica + bytesACopiar
-		AgregarACache(pid, nuevaDireccion, restante, modifica)
+	} else if err := reemplazarEntradaCache(algoritmo.elegirVictima(globalsCpu.Cache), nuevaEntrada); err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("PID %d - No se pudo reemplazar una entrada para la página %d: %s", pid, pagina, err))
+		return false
 	}
+	return true
 }
 
 func avanzarPuntero() {
 	globalsCpu.PunteroClock = (globalsCpu.PunteroClock + 1) % len(globalsCpu.Cache)
 }
 
-func reemplazarPorClock(nuevaEntrada globalsCpu.EntradaCache) {
+// Devuelve la entrada del puntero y lo deja apuntando a la siguiente
+func victimaEnPuntero() int {
+	victima := globalsCpu.PunteroClock
+	avanzarPuntero()
+	return victima
+}
+
+func victimaClock() int {
 	cantidadEntradas := len(globalsCpu.Cache)
 
 	// Primera vuelta: limpia Uso en las páginas con Uso == true, y reemplaza si encuentra Uso == false
@@ -158,58 +393,35 @@ func reemplazarPorClock(nuevaEntrada globalsCpu.EntradaCache) {
 		actual := &globalsCpu.Cache[globalsCpu.PunteroClock]
 
 		if !actual.Uso {
-			reemplazarEntradaCache(globalsCpu.PunteroClock, nuevaEntrada)
-			avanzarPuntero()
-			return
+			return victimaEnPuntero()
 		}
 
 		actual.Uso = false
 		avanzarPuntero()
 	}
 
-	// Segunda vuelta: ahora alguna tendrá Uso == false
-	for i := 0; i < cantidadEntradas; i++ {
-		actual := &globalsCpu.Cache[globalsCpu.PunteroClock]
-
-		if !actual.Uso {
-			reemplazarEntradaCache(globalsCpu.PunteroClock, nuevaEntrada)
-			avanzarPuntero()
-			return
-		}
-
-		avanzarPuntero()
-	}
+	// Segunda vuelta: ahora todas tienen Uso == false
+	return victimaEnPuntero()
 }
 
-func reemplazarPorClockM(nuevaEntrada globalsCpu.EntradaCache, pid int, direccionLogica int) {
+func victimaClockM() int {
 	cantidad := len(globalsCpu.Cache)
-	pageSize := globalsCpu.Memoria.TamanioPagina
 
 	// FASE 1: Buscar Uso = 0 && Modificado = 0
 	for i := 0; i < cantidad; i++ {
 		actual := &globalsCpu.Cache[globalsCpu.PunteroClock]
 		if !actual.Uso && !actual.Modificado {
-			reemplazarEntradaCache(globalsCpu.PunteroClock, nuevaEntrada)
-			avanzarPuntero()
-			return
+			return victimaEnPuntero()
 		}
 		avanzarPuntero()
 	}
 
-	// FASE 2: Buscar Uso = 0 && Modificado = 1, limpiando Uso selectivamente
+	// FASE 2: Buscar Uso = 0 && Modificado = 1, limpiando Uso selectivamente.
+	// reemplazarEntradaCache baja la página modificada antes de pisarla
 	for i := 0; i < cantidad; i++ {
 		actual := &globalsCpu.Cache[globalsCpu.PunteroClock]
 		if !actual.Uso && actual.Modificado {
-			direccionLogicaActual := actual.Pagina * pageSize
-			marco, err := mmuUtils.ObtenerMarco(actual.Pid, direccionLogicaActual)
-			if err != nil {
-				clientUtils.Logger.Error(fmt.Sprintf("No se pudo obtener marco para página %d al hacer write", actual.Pagina))
-			} else {
-				consultaWrite(actual.Pid, marco, direccionLogicaActual, actual.Contenido)
-			}
-			reemplazarEntradaCache(globalsCpu.PunteroClock, nuevaEntrada)
-			avanzarPuntero()
-			return
+			return victimaEnPuntero()
 		}
 
 		// Limpieza selectiva: solo si Uso está en true
@@ -219,155 +431,93 @@ func reemplazarPorClockM(nuevaEntrada globalsCpu.EntradaCache, pid int, direccio
 		avanzarPuntero()
 	}
 
-	// FASE 3: Nueva pasada FASE 1 (debería encontrar sí o sí)
+	// FASE 3: Nueva pasada FASE 1
 	for i := 0; i < cantidad; i++ {
 		actual := &globalsCpu.Cache[globalsCpu.PunteroClock]
 		if !actual.Uso && !actual.Modificado {
-			reemplazarEntradaCache(globalsCpu.PunteroClock, nuevaEntrada)
-			avanzarPuntero()
-			return
+			return victimaEnPuntero()
 		}
 		avanzarPuntero()
 	}
 
-	// FASE 4: Nueva pasada FASE 2 (forzado)
-	for i := 0; i < cantidad; i++ {
-		actual := &globalsCpu.Cache[globalsCpu.PunteroClock]
-		if !actual.Uso && actual.Modificado {
-			direccionLogicaActual := actual.Pagina * pageSize
-			marco, err := mmuUtils.ObtenerMarco(actual.Pid, direccionLogicaActual)
-			if err != nil {
-				clientUtils.Logger.Error(fmt.Sprintf("No se pudo obtener marco para página %d en fase 4", actual.Pagina))
-			} else {
-				consultaWrite(actual.Pid, marco, direccionLogicaActual, actual.Contenido)
-			}
-			reemplazarEntradaCache(globalsCpu.PunteroClock, nuevaEntrada)
-			avanzarPuntero()
-			return
-		}
-		avanzarPuntero()
-	}
+	// FASE 4: después de la fase 2 todas tienen Uso = 0 y son modificadas, sirve la del puntero
+	return victimaEnPuntero()
 }
 
-func reemplazarEntradaCache(indice int, nueva globalsCpu.EntradaCache) {
+// Si la entrada a reemplazar está modificada se baja a Memoria antes de pisarla. Si no se pudo bajar
+// la entrada se queda y la nueva no entra, para no perder lo escrito
+func reemplazarEntradaCache(indice int, nueva globalsCpu.EntradaCache) error {
 	evictada := globalsCpu.Cache[indice]
 
-	//clientUtils.Logger.Debug("El valor de evictada es: ", "Evictada", evictada.Contenido)
-
-	//clientUtils.Logger.Debug("La cache a modificar y la que la modifica son", "Modificada", evictada, "Modificadora", nueva)
-
 	if evictada.Modificado {
-		//clientUtils.Logger.Info(fmt.Sprintf("Cache Replace - Página %d modificada → escribir en Memoria", evictada.Pagina))
-
-		// 🔍 Buscar marco real
-		direccionLogica := mmuUtils.ObtenerDireccionLogica(evictada.Pagina)
-		marco, err := mmuUtils.ObtenerMarco(evictada.Pid, direccionLogica)
-		if err != nil {
-			clientUtils.Logger.Error(fmt.Sprintf("No se pudo obtener marco de la página %d del PID %d", evictada.Pagina, evictada.Pid))
-			return
-		}
-
-		// Leer toda la página original desde Memoria
-		valoresLeer := []string{
-			strconv.Itoa(evictada.Pid),
-			strconv.Itoa(marco),
-			strconv.Itoa(len(evictada.Contenido)),
-		}
-		paqueteLeer := clientUtils.Paquete{Valores: valoresLeer}
-
-		paginaCompleta := clientUtils.EnviarPaqueteConRespuestaBody(
-			globalsCpu.CpuConfig.IpMemory,
-			globalsCpu.CpuConfig.PortMemory,
-			"readPagina",
-			paqueteLeer,
-		)
-
-		for i := 0; i < len(evictada.Contenido) && i < len(paginaCompleta); i++ {
-			if evictada.Contenido[i] == 0x00 {
-				evictada.Contenido[i] = paginaCompleta[i]
-			}
-		}
-
-		// Armás el paquete para mandarlo a Memoria
-		valores := []string{
-			strconv.Itoa(evictada.Pid),
-			strconv.Itoa(marco),
-			strconv.Itoa(len(evictada.Contenido)),
+		if err := bajarEntrada(evictada); err != nil {
+			return err
 		}
-		for _, b := range evictada.Contenido {
-			valores = append(valores, strconv.Itoa(int(b)))
-		}
-
-		paquete := clientUtils.Paquete{Valores: valores}
-		clientUtils.EnviarPaquete(
-			globalsCpu.CpuConfig.IpMemory,
-			globalsCpu.CpuConfig.PortMemory,
-			"writePagina",
-			paquete,
-		)
-
-		clientUtils.Logger.Info(fmt.Sprintf("PID %d - Memory Update - Página %d - Frame %d", evictada.Pid, evictada.Pagina, marco))
+		escriturasAMemoria++
+		desalojosModificados++
+		clientUtils.Logger.Info(fmt.Sprintf("PID %d - Memory Update - Página %d - Frame %d", evictada.Pid, evictada.Pagina, evictada.Marco))
 	}
 
 	time.Sleep(time.Millisecond * time.Duration(globalsCpu.CpuConfig.CacheDelay))
 
 	globalsCpu.Cache[indice] = nueva
+	reemplazos++
 	clientUtils.Logger.Info(fmt.Sprintf("PID %d - Cache Add - Página %d", nueva.Pid, nueva.Pagina))
+	return nil
 }
 
-func FlushPaginasModificadas(pid int) {
-	globalsCpu.CacheMutex.Lock()
-	defer globalsCpu.CacheMutex.Unlock()
-	pageSize := globalsCpu.Memoria.TamanioPagina
-	for _, entrada := range globalsCpu.Cache {
-		//clientUtils.Logger.Debug("No entra al if", "entrada", entrada.Pid, "pid", pid, "entrada modificada?", entrada.Modificado)
-		if entrada.Pid == pid && entrada.Modificado {
-			marco, err := mmuUtils.ObtenerMarco(entrada.Pid, mmuUtils.ObtenerDireccionLogica(entrada.Pagina))
-			if err != nil {
-				clientUtils.Logger.Error(fmt.Sprintf("No se encontró el marco para la página %d del PID %d", entrada.Pagina, pid))
-				continue
-			}
-			//clientUtils.Logger.Debug("No hay deadlock")
-
-			valores := []string{
-				strconv.Itoa(pid),
-				strconv.Itoa(marco),
-				strconv.Itoa(len(entrada.Contenido)),
-			}
-			for _, b := range entrada.Contenido {
-				valores = append(valores, strconv.Itoa(int(b)))
-			}
-
-			paquete := clientUtils.Paquete{Valores: valores}
-
-			var bytesAEnviar []byte
-			for _, v := range paquete.Valores[3:] {
-				n, err := strconv.Atoi(v)
-				if err != nil {
-					// Si algo falla lo reemplazo por un '?'
-					bytesAEnviar = append(bytesAEnviar, '?')
-					continue
-				}
-				bytesAEnviar = append(bytesAEnviar, byte(n))
-			}
+// Escribe la página entera en el marco de la entrada. No se vuelve a traducir: el marco salió de la
+// traducción para escribir que hizo la instrucción, así que el copy-on-write ya está resuelto
+func bajarEntrada(entrada globalsCpu.EntradaCache) error {
+	valores := []string{
+		strconv.Itoa(entrada.Pid),
+		strconv.Itoa(entrada.Marco),
+		strconv.Itoa(len(entrada.Contenido)),
+	}
+	for _, b := range entrada.Contenido {
+		valores = append(valores, strconv.Itoa(int(b)))
+	}
+	paquete := clientUtils.Paquete{Valores: valores}
 
-			clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: Escribir - Dirección Física: %d - Valor: %s", pid, marco*pageSize, string(bytesAEnviar)))
+	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: Escribir - Dirección Física: %d - Valor: %s", entrada.Pid, entrada.Marco*globalsCpu.Memoria.TamanioPagina, string(entrada.Contenido)))
 
-			clientUtils.EnviarPaquete(
-				globalsCpu.CpuConfig.IpMemory,
-				globalsCpu.CpuConfig.PortMemory,
-				"writePagina",
-				paquete,
-			)
-		}
+	respuesta := clientUtils.EnviarPaqueteConRespuestaBody(
+		globalsCpu.CpuConfig.IpMemory,
+		globalsCpu.CpuConfig.PortMemory,
+		"writePagina",
+		paquete,
+	)
+	if respuesta == nil {
+		return fmt.Errorf("no se recibió respuesta de Memoria al bajar la página %d", entrada.Pagina)
 	}
+	return mmuUtils.FallaEnRespuesta(respuesta, mmuUtils.ObtenerDireccionLogica(entrada.Pagina))
 }
 
-func LimpiarCache() {
+// Baja a Memoria las páginas modificadas del proceso y saca de la caché todas las entradas salvo las
+// que no se pudieron bajar, que se quedan para no perder lo escrito. Devuelve el primer error
+func FlushPaginasModificadas(pid int) error {
 	globalsCpu.CacheMutex.Lock()
 	defer globalsCpu.CacheMutex.Unlock()
 
-	globalsCpu.Cache = []globalsCpu.EntradaCache{}
+	var primerError error
+	pendientes := []globalsCpu.EntradaCache{}
+	for _, entrada := range globalsCpu.Cache {
+		if entrada.Modificado && entrada.Pid == pid {
+			if err := bajarEntrada(entrada); err != nil {
+				clientUtils.Logger.Error(fmt.Sprintf("PID %d - No se pudo bajar la página %d a Memoria: %s", pid, entrada.Pagina, err))
+				if primerError == nil {
+					primerError = err
+				}
+				pendientes = append(pendientes, entrada)
+				continue
+			}
+			escriturasAMemoria++
+		} else if entrada.Modificado {
+			pendientes = append(pendientes, entrada)
+		}
+	}
+	globalsCpu.Cache = pendientes
+	return primerError
 }
 
 func consultaRead(pid int, marco int) ([]byte, error) {
@@ -397,65 +547,3 @@ func consultaRead(pid int, marco int) ([]byte, error) {
 
 	return paginaCompleta, nil
 }
-
-func consultaWrite(pid int, marco int, direccionLogica int, datos []byte) error {
-	pageSize := globalsCpu.Memoria.TamanioPagina
-	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
-
-	// Primero leemos toda la página para modificar solo los bytes necesarios
-	valoresLeer := []string{
-		strconv.Itoa(pid),
-		strconv.Itoa(marco),
-		strconv.Itoa(pageSize),
-	}
-	paqueteLeer := clientUtils.Paquete{Valores: valoresLeer}
-
-	paginaCompleta := clientUtils.EnviarPaqueteConRespuestaBody(
-		globalsCpu.CpuConfig.IpMemory,
-		globalsCpu.CpuConfig.PortMemory,
-		"readPagina",
-		paqueteLeer,
-	)
-
-	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: Leer - Dirección Física: %d - Valor Leído: %s", pid, marco*pageSize, string(paginaCompleta)))
-
-	if len(paginaCompleta) != pageSize {
-		return fmt.Errorf("no se pudo leer página completa antes de escribir")
-	}
-
-	// Modificar solo el rango correspondiente
-	copy(paginaCompleta[desplazamiento:], datos)
-
-	// Preparar paquete para escribir página completa
-	valores := []string{
-		strconv.Itoa(pid),
-		strconv.Itoa(marco),
-		strconv.Itoa(pageSize),
-	}
-	for _, b := range paginaCompleta {
-		valores = append(valores, strconv.Itoa(int(b)))
-	}
-	paquete := clientUtils.Paquete{Valores: valores}
-
-	var bytesAEnviar []byte
-	for _, v := range paquete.Valores[3:] {
-		n, err := strconv.Atoi(v)
-		if err != nil {
-			// Si algo falla lo reemplazo por un '?'
-			bytesAEnviar = append(bytesAEnviar, '?')
-			continue
-		}
-		bytesAEnviar = append(bytesAEnviar, byte(n))
-	}
-
-	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: Escribir - Dirección Física: %d - Valor: %s", pid, marco*pageSize, string(bytesAEnviar)))
-
-	clientUtils.EnviarPaquete(
-		globalsCpu.CpuConfig.IpMemory,
-		globalsCpu.CpuConfig.PortMemory,
-		"writePagina",
-		paquete,
-	)
-
-	return nil
-}
@@ -5,8 +5,10 @@ import (
 	"net/http"
 	"os"
 
+	cacheUtils "github.com/sisoputnfrba/tp-golang/cpu/cache"
 	cpuUtils "github.com/sisoputnfrba/tp-golang/cpu/cpuUtils"
 	globalscpu "github.com/sisoputnfrba/tp-golang/cpu/globalsCpu"
+	tlbUtils "github.com/sisoputnfrba/tp-golang/cpu/tlb"
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
 )
 
@@ -24,12 +26,25 @@ func main() {
 	globalscpu.SetIdentificador(identificador)
 	globalscpu.CpuConfig = cpuUtils.IniciarConfiguracion("config.json")
 	cpuUtils.ObtenerInfoMemoria()
+	tlbUtils.IniciarTLB()
+	cacheUtils.IniciarCache()
 
 	// Registrar endpoints
 	mux := http.NewServeMux()
 	mux.HandleFunc("/recibirProceso", cpuUtils.RecibirProceso)
 	mux.HandleFunc("/recibirInterrupcion", cpuUtils.RecibirInterrupcion)
 	mux.HandleFunc("/finSyscallInitProc", cpuUtils.FinSycall)
+	mux.HandleFunc("/invalidarTLB", cpuUtils.InvalidarTLB)
+	mux.HandleFunc("/metricas", cpuUtils.Metricas)
+
+	// Debugger: breakpoints, pausa, paso a paso e inspección del proceso pausado
+	mux.HandleFunc("/debug/agregarBreakpoint", cpuUtils.DebugAgregarBreakpoint)
+	mux.HandleFunc("/debug/quitarBreakpoint", cpuUtils.DebugQuitarBreakpoint)
+	mux.HandleFunc("/debug/pausar", cpuUtils.DebugPausar)
+	mux.HandleFunc("/debug/paso", cpuUtils.DebugPaso)
+	mux.HandleFunc("/debug/continuar", cpuUtils.DebugContinuar)
+	mux.HandleFunc("/debug/estado", cpuUtils.DebugEstado)
+	mux.HandleFunc("/debug/leerMemoria", cpuUtils.DebugLeerMemoria)
 
 	// Buscar puerto disponible y levantar servidor
 	listener, puertoLibre, err := clientUtils.EncontrarPuertoDisponible(globalscpu.CpuConfig.IpCpu, globalscpu.CpuConfig.PortCpu)
@@ -41,7 +56,10 @@ func main() {
 	// Hacer handshake al Kernel
 	cpuUtils.EnviarHandshakeAKernel(identificador, puertoLibre)
 
-	//TODO: HANDSHAKE CON MEMORIA (CAMBIANDO PUERTO)
+	// Con la TLB etiquetada por PID Memoria tiene que poder avisar qué traducciones dejan de valer
+	if globalscpu.CpuConfig.TlbAsid && globalscpu.CpuConfig.TlbEntries != 0 {
+		cpuUtils.EnviarHandshakeAMemoria(identificador, puertoLibre)
+	}
 
 	// Servir usando el listener ya abierto
 	err = http.Serve(listener, mux)
@@ -3,6 +3,7 @@ package cpuUtils
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,10 +13,13 @@ import (
 	"sync"
 
 	cacheUtils "github.com/sisoputnfrba/tp-golang/cpu/cache"
+	debugger "github.com/sisoputnfrba/tp-golang/cpu/debugger"
 	globalsCpu "github.com/sisoputnfrba/tp-golang/cpu/globalsCpu"
 	mmuUtils "github.com/sisoputnfrba/tp-golang/cpu/mmu"
 	tlbUtils "github.com/sisoputnfrba/tp-golang/cpu/tlb"
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
+	instrucciones "github.com/sisoputnfrba/tp-golang/utils/instrucciones"
+	registros "github.com/sisoputnfrba/tp-golang/utils/registros"
 	serverUtils "github.com/sisoputnfrba/tp-golang/utils/server"
 )
 
@@ -29,13 +33,48 @@ const (
 	INIT_PROC   = "INIT_PROC"
 	DUMP_MEMORY = "DUMP_MEMORY"
 	EXIT        = "EXIT"
+	KILL        = "KILL"
+	WAIT_PID    = "WAIT_PID"
+	FORK        = "FORK"
+	// Syscalls sobre recursos del Kernel
+	WAIT         = "WAIT"
+	SIGNAL       = "SIGNAL"
+	MUTEX_LOCK   = "MUTEX_LOCK"
+	MUTEX_UNLOCK = "MUTEX_UNLOCK"
+	// Syscalls de memoria compartida
+	SHM_CREATE = "SHM_CREATE"
+	SHM_ATTACH = "SHM_ATTACH"
+	SHM_DETACH = "SHM_DETACH"
+	// Cambio del tamaño del proceso en bytes
+	BRK = "BRK"
+	// Instrucciones sobre registros
+	SET = "SET"
+	SUM = "SUM"
+	SUB = "SUB"
+	MUL = "MUL"
+	CMP = "CMP"
+	JNZ = "JNZ"
+	JZ  = "JZ"
+	// Movimientos entre registros y memoria de usuario
+	MOV_IN  = "MOV_IN"
+	MOV_OUT = "MOV_OUT"
+	// Motivo de devolución cuando un acceso a memoria encuentra una página no cargada
+	PAGE_FAULT = "PAGE_FAULT"
+	// Motivo de devolución cuando un acceso a memoria está fuera del proceso o no tiene permiso
+	SEG_FAULT = "SEG_FAULT"
+	// Motivo de devolución cuando la instrucción no se puede ejecutar (operandos inválidos, error de Memoria)
+	ERROR_EJECUCION = "ERROR_EJECUCION"
 	// Constantes para los tipos de interrupción
-	INVALID = "INVALID"
+	INVALID  = "INVALID"
+	DESALOJO = "DESALOJO"
+	// Respuestas del Kernel a las syscalls sincrónicas
+	SYSCALL_CONTINUAR = "CONTINUAR"
+	SYSCALL_BLOQUEADO = "BLOQUEADO"
 )
 
 var cancelProcesoActual context.CancelFunc
 var ctxMutex sync.Mutex
-var semSycallInitProc = make(chan struct{})
+var semSyscall = make(chan []string)
 
 // Representa un proceso con su PID y su Program Counter (PC)
 type Proceso struct {
@@ -110,11 +149,25 @@ func ObtenerInfoMemoria() {
 	)*/
 }
 
+// Respuesta del Kernel a una syscall sincrónica, el proceso sigue ejecutando
 func FinSycall(w http.ResponseWriter, r *http.Request) {
-	semSycallInitProc <- struct{}{}
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	semSyscall <- paquete.Valores
 	w.WriteHeader(http.StatusOK)
 }
 
+// INIT_PROC, FORK, KILL, WAIT_PID, BRK y las syscalls de recursos y de memoria compartida esperan la
+// respuesta del Kernel con el proceso en la CPU, salvo un KILL al propio proceso que se trata como EXIT
+func esperaRespuestaDelKernel(proceso *globalsCpu.Proceso, cod_op string, variables []string) bool {
+	switch cod_op {
+	case INIT_PROC, FORK, WAIT_PID, BRK, WAIT, SIGNAL, MUTEX_LOCK, MUTEX_UNLOCK, SHM_CREATE, SHM_ATTACH, SHM_DETACH:
+		return true
+	case KILL:
+		return len(variables) == 1 && variables[0] != strconv.Itoa(proceso.Pid)
+	}
+	return false
+}
+
 // Recibe un proceso del Kernel y lo loguea
 func RecibirProceso(w http.ResponseWriter, r *http.Request) {
 	paquete := serverUtils.RecibirPaquetes(w, r)
@@ -135,9 +188,25 @@ func RecibirProceso(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	// Los registros son opcionales, un proceso nuevo arranca con todos en 0
+	var contexto registros.Registros
+	if len(paquete.Valores) > 2 {
+		contexto, err = registros.Deserializar(paquete.Valores[2])
+		if err != nil {
+			clientUtils.Logger.Error("Error al decodificar los registros del proceso")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
 	proceso := &globalsCpu.Proceso{
-		Pid: pid,
-		Pc:  pc,
+		Pid:       pid,
+		Pc:        pc,
+		Registros: contexto,
+	}
+
+	// Lo que quedó pendiente era para la ráfaga anterior, aunque sea del mismo PID
+	if motivo, pidInterrumpido, ok := tomarInterrupcion(); ok {
+		descartarInterrupcion(motivo, pidInterrumpido)
 	}
 
 	clientUtils.Logger.Info(fmt.Sprintf("## Llega proceso - PID: %d, PC: %d", pid, pc))
@@ -199,6 +268,17 @@ func HandleProceso(ctx context.Context, proceso *globalsCpu.Proceso) {
 		default:
 			// seguir normalmente
 		}
+		// Breakpoints y paso a paso del debugger
+		switch debugger.Esperar(ctx, proceso) {
+		case debugger.CANCELADO:
+			clientUtils.Logger.Warn(fmt.Sprintf("## PID: %d - Cancelado por llegada de nuevo proceso", proceso.Pid))
+			return
+		case debugger.INTERRUMPIDO:
+			if atenderInterrupcion(proceso) {
+				return
+			}
+			continue
+		}
 		//#FETCH
 		instruccion, ok := PedirSiguienteInstruccionMemoria(proceso)
 		if !ok {
@@ -215,20 +295,29 @@ func HandleProceso(ctx context.Context, proceso *globalsCpu.Proceso) {
 		//clientUtils.Logger.Info("## Ejecutando instrucción")
 		cont := ExecuteInstruccion(proceso, cod_op, variables)
 
-		if !cont && cod_op != INIT_PROC {
-			if cod_op == EXIT {
+		if !cont && !esperaRespuestaDelKernel(proceso, cod_op, variables) {
+			if cod_op == EXIT || cod_op == KILL {
 				clientUtils.Logger.Info("## Proceso finalizado")
 			}
 			return
-		} else if cod_op == INIT_PROC {
-			<-semSycallInitProc
+		} else if !cont {
+			respuesta := <-semSyscall
+			if len(respuesta) > 0 && respuesta[0] == SYSCALL_BLOQUEADO {
+				clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - Bloqueado por %s", proceso.Pid, cod_op))
+				return
+			}
+			if cod_op == INIT_PROC && len(respuesta) > 1 {
+				clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - INIT_PROC creó al proceso %s", proceso.Pid, respuesta[1]))
+			}
+			if cod_op == FORK && len(respuesta) > 1 {
+				asignarResultadoFork(proceso, variables[0], respuesta[1])
+			}
+			if cod_op == BRK && len(respuesta) > 1 {
+				asignarResultadoBrk(proceso, variables, respuesta[1])
+			}
 		}
 		clientUtils.Logger.Info("## Verificando interrupciones")
-		if globalsCpu.Interrupciones.ExisteInterrupcion.Load() {
-			clientUtils.Logger.Info("## Interrupcion recibida")
-			EnviarResultadoAKernel(proceso.Pc, globalsCpu.Interrupciones.Motivo, nil)
-			globalsCpu.Interrupciones.ExisteInterrupcion.Store(false)
-			globalsCpu.Interrupciones.Motivo = ""
+		if atenderInterrupcion(proceso) {
 			return
 		}
 
@@ -236,20 +325,73 @@ func HandleProceso(ctx context.Context, proceso *globalsCpu.Proceso) {
 
 }
 
+// Devuelve true si había una interrupción para el proceso y se lo devolvió al Kernel
+func atenderInterrupcion(proceso *globalsCpu.Proceso) bool {
+	motivo, pidInterrumpido, ok := tomarInterrupcion()
+	if !ok {
+		return false
+	}
+
+	// Una interrupción dirigida a otro PID llegó tarde y se descarta
+	if pidInterrumpido == proceso.Pid {
+		clientUtils.Logger.Info(fmt.Sprintf("## Interrupcion recibida - Motivo: %s", motivo))
+		devolverAlKernel(proceso, motivo, nil)
+		return true
+	}
+	descartarInterrupcion(motivo, pidInterrumpido)
+	return false
+}
+
+// Saca la interrupción pendiente, si hay
+func tomarInterrupcion() (string, int, bool) {
+	globalsCpu.Interrupciones.Mutex.Lock()
+	defer globalsCpu.Interrupciones.Mutex.Unlock()
+	if !globalsCpu.Interrupciones.ExisteInterrupcion.Swap(false) {
+		return "", 0, false
+	}
+	motivo := globalsCpu.Interrupciones.Motivo
+	globalsCpu.Interrupciones.Motivo = ""
+	return motivo, globalsCpu.Interrupciones.Pid, true
+}
+
+// El Kernel espera la CPU de un desalojo, así que se le avisa que no se hizo
+func descartarInterrupcion(motivo string, pid int) {
+	clientUtils.Logger.Warn(fmt.Sprintf("## Interrupcion %s descartada, era para el PID %d", motivo, pid))
+	if motivo != DESALOJO {
+		return
+	}
+	valores := []string{globalsCpu.Identificador, motivo, strconv.Itoa(pid)}
+	go clientUtils.GenerarYEnviarPaquete(valores, globalsCpu.CpuConfig.IpKernel, globalsCpu.CpuConfig.PortKernel, "interrupcionDescartada")
+}
+
 func RecibirInterrupcion(w http.ResponseWriter, r *http.Request) {
 	clientUtils.Logger.Info("## Llega interrupción al puerto Interrupt")
 	paquete := serverUtils.RecibirPaquetes(w, r)
-	globalsCpu.Interrupciones.ExisteInterrupcion.Store(true)
+	if len(paquete.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, err := strconv.Atoi(paquete.Valores[1])
+	if err != nil {
+		clientUtils.Logger.Error("Error al convertir PID de la interrupción a int")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	globalsCpu.Interrupciones.Mutex.Lock()
 	globalsCpu.Interrupciones.Motivo = paquete.Valores[0]
+	globalsCpu.Interrupciones.Pid = pid
+	globalsCpu.Interrupciones.ExisteInterrupcion.Store(true)
+	globalsCpu.Interrupciones.Mutex.Unlock()
 	w.WriteHeader(http.StatusOK)
 }
 
 //----------------------------------------------------------------------
 
-func EnviarResultadoAKernel(pc int, cod_op string, args []string) {
-	pcStr := strconv.Itoa(pc)
+// Devuelve el proceso al Kernel con todo su contexto: PC, registros, motivo y argumentos
+func EnviarResultadoAKernel(proceso *globalsCpu.Proceso, cod_op string, args []string) {
+	pcStr := strconv.Itoa(proceso.Pc)
 
-	ids := []string{globalsCpu.Identificador, pcStr, cod_op}
+	ids := []string{globalsCpu.Identificador, pcStr, proceso.Registros.Serializar(), cod_op}
 
 	valores := append(ids, args...)
 
@@ -270,23 +412,15 @@ func Decode(instruccion string) (op string, args []string) {
 func DecodeInstruccion(instruccion string) (cod_op string, variables []string) {
 	cod_op, variables = Decode(instruccion)
 
-	switch cod_op {
-	case NOOP, EXIT, DUMP_MEMORY:
-		if len(variables) != 0 {
-			clientUtils.Logger.Error(fmt.Sprintf("cantidad de parametros recibidos en la instruccion %s incorrecto, no se deben ingresar parametros para esta instruccion", cod_op))
-		}
-	case GOTO:
-		cod_op = GOTO
-		if len(variables) != 1 {
-			clientUtils.Logger.Error("cantidad de parametros recibidos en la instruccion GOTO incorrecto, se debe ingresar 1 parametro")
-		}
-	case READ, WRITE, IO, INIT_PROC:
-		if len(variables) != 2 {
-			clientUtils.Logger.Error(fmt.Sprintf("cantidad de parametros recibidos en la instruccion %s incorrecto, se deben ingresar 2 parametros", cod_op))
-		}
-	default:
+	// La cantidad de parámetros de cada instrucción sale de la tabla compartida con el validador de scripts
+	formato, ok := instrucciones.Tabla[cod_op]
+	if !ok {
 		clientUtils.Logger.Error("Instrucción inválida")
-		cod_op = INVALID
+		return INVALID, append([]string{cod_op}, variables...)
+	}
+	if _, ok := formato.Forma(len(variables)); !ok {
+		clientUtils.Logger.Error(fmt.Sprintf("cantidad de parametros recibidos en la instruccion %s incorrecto, se deben ingresar %s", cod_op, formato.Aridad()))
+		return INVALID, append([]string{cod_op}, variables...)
 	}
 	return cod_op, variables
 }
@@ -302,80 +436,240 @@ func ExecuteInstruccion(proceso *globalsCpu.Proceso, cod_op string, variables []
 
 	case WRITE:
 		//clientUtils.Logger.Info("## Ejecutando WRITE")
-		direccion := variables[0]
+		// La dirección puede ser un número o un registro que la contenga
+		direccion, ok := valorOperando(proceso, variables[0])
 		dato := variables[1]
-		direccionInt, err := strconv.Atoi(direccion)
 
-		if err != nil {
-			clientUtils.Logger.Error("WRITE: argumento inválido, no es un número")
+		if !ok {
+			devolverConError(proceso, fmt.Sprintf("WRITE: dirección inválida %s, no es un número ni un registro", variables[0]))
 			return false
 		}
 
-		writeMemoria(proceso.Pid, direccionInt, dato)
+		if atenderErrorDeMemoria(proceso, writeMemoria(proceso.Pid, int(direccion), dato)) {
+			return false
+		}
 		proceso.Pc++
 		return true
 	case READ:
 		//clientUtils.Logger.Info("## Ejecutando READ")
-		direccion := variables[0]
-		tamanio := variables[1]
-		direccionInt, err := strconv.Atoi(direccion)
-		if err != nil {
-			clientUtils.Logger.Error("READ: argumento inválido, no es un número")
+		direccion, okDireccion := valorOperando(proceso, variables[0])
+		tamanio, okTamanio := valorOperando(proceso, variables[1])
+
+		if !okDireccion || !okTamanio {
+			devolverConError(proceso, fmt.Sprintf("READ: argumento inválido en %s, no es un número ni un registro", strings.Join(variables, " ")))
 			return false
 		}
-		tamanioInt, err := strconv.Atoi(tamanio)
 
-		if err != nil {
-			clientUtils.Logger.Error("READ: argumento inválido, no es un número")
+		if atenderErrorDeMemoria(proceso, readMemoria(proceso.Pid, int(direccion), int(tamanio))) {
+			return false
+		}
+		proceso.Pc++
+		return true
+	case MOV_IN:
+		if atenderErrorDeMemoria(proceso, movIn(proceso, variables[0], variables[1])) {
+			return false
+		}
+		proceso.Pc++
+		return true
+	case MOV_OUT:
+		if atenderErrorDeMemoria(proceso, movOut(proceso, variables[0], variables[1])) {
 			return false
 		}
-
-		readMemoria(proceso.Pid, direccionInt, tamanioInt)
 		proceso.Pc++
 		return true
 	case GOTO:
 		//clientUtils.Logger.Info("## Ejecutando GOTO")
 		nuevoPC, err := strconv.Atoi(variables[0])
 		if err != nil {
-			clientUtils.Logger.Warn("GOTO: argumento inválido, no es un número")
-			break
+			devolverConError(proceso, fmt.Sprintf("GOTO: destino inválido %s, no es un número", variables[0]))
+			return false
 		}
 		proceso.Pc = nuevoPC
 		return true
-	case IO, INIT_PROC, DUMP_MEMORY, EXIT:
+	case SET, SUM, SUB, MUL, CMP:
+		if !ejecutarOperacion(proceso, cod_op, variables) {
+			devolverConError(proceso, fmt.Sprintf("%s %s: operandos inválidos", cod_op, strings.Join(variables, " ")))
+			return false
+		}
+		proceso.Pc++
+		return true
+	case JNZ, JZ:
+		saltar, destino, ok := evaluarSalto(proceso, cod_op, variables)
+		if !ok {
+			devolverConError(proceso, fmt.Sprintf("%s %s: operandos inválidos", cod_op, strings.Join(variables, " ")))
+			return false
+		}
+		if saltar {
+			proceso.Pc = destino
+		} else {
+			proceso.Pc++
+		}
+		return true
+	case IO, INIT_PROC, FORK, DUMP_MEMORY, EXIT, KILL, WAIT_PID, BRK, WAIT, SIGNAL, MUTEX_LOCK, MUTEX_UNLOCK, SHM_CREATE, SHM_ATTACH, SHM_DETACH:
 		Syscall(proceso, cod_op, variables)
 		return false // ← Esto evita volver al for
 	default:
 		clientUtils.Logger.Error("## Instruccion no reconocida")
+		devolverConError(proceso, fmt.Sprintf("instrucción inválida: %s", strings.Join(variables, " ")))
 		return false
 	}
-	return false
+}
+
+// La instrucción no se puede ejecutar: el proceso vuelve al Kernel con el motivo para que lo finalice
+// en vez de quedar en EXEC con la CPU tomada
+func devolverConError(proceso *globalsCpu.Proceso, detalle string) {
+	clientUtils.Logger.Error(fmt.Sprintf("## PID: %d - Error de ejecución - %s", proceso.Pid, detalle))
+	devolverAlKernel(proceso, ERROR_EJECUCION, []string{detalle})
+}
+
+// Libera la CPU del proceso y lo devuelve al Kernel con el motivo. Si la caché no pudo bajar sus
+// páginas modificadas, la memoria del proceso no tiene lo que escribió y vuelve con error
+func devolverAlKernel(proceso *globalsCpu.Proceso, cod_op string, args []string) {
+	if err := LimpiarProceso(proceso.Pid); err != nil {
+		detalle := fmt.Sprintf("no se pudieron bajar las páginas modificadas de la caché: %s", err)
+		clientUtils.Logger.Error(fmt.Sprintf("## PID: %d - Error de ejecución - %s", proceso.Pid, detalle))
+		cod_op, args = ERROR_EJECUCION, []string{detalle}
+	}
+	EnviarResultadoAKernel(proceso, cod_op, args)
+}
+
+// Un operando puede ser un registro o un valor inmediato
+func valorOperando(proceso *globalsCpu.Proceso, operando string) (uint32, bool) {
+	if valor, ok := proceso.Registros.Obtener(operando); ok {
+		return valor, true
+	}
+	valor, err := instrucciones.Numero(operando)
+	if err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("Operando inválido: %s", operando))
+		return 0, false
+	}
+	return uint32(valor), true
+}
+
+// SET registro valor, SUM/SUB/MUL destino origen, CMP a b
+func ejecutarOperacion(proceso *globalsCpu.Proceso, cod_op string, variables []string) bool {
+	if len(variables) != 2 {
+		return false
+	}
+	valor, ok := valorOperando(proceso, variables[1])
+	if !ok {
+		return false
+	}
+	if cod_op == SET {
+		if !proceso.Registros.Asignar(variables[0], valor) {
+			clientUtils.Logger.Error(fmt.Sprintf("SET: registro inválido %s", variables[0]))
+			return false
+		}
+		return true
+	}
+
+	actual, ok := valorOperando(proceso, variables[0])
+	if !ok {
+		return false
+	}
+	if cod_op == CMP {
+		proceso.Registros.Comparar(actual, valor)
+		return true
+	}
+
+	var resultado uint32
+	switch cod_op {
+	case SUM:
+		resultado = actual + valor
+	case SUB:
+		resultado = actual - valor
+	case MUL:
+		resultado = actual * valor
+	}
+	if !proceso.Registros.Asignar(variables[0], resultado) {
+		clientUtils.Logger.Error(fmt.Sprintf("%s: el destino debe ser un registro, se recibió %s", cod_op, variables[0]))
+		return false
+	}
+	// FLAGS refleja el valor que quedó guardado, ya truncado al tamaño del registro
+	guardado, _ := proceso.Registros.Obtener(variables[0])
+	proceso.Registros.ActualizarCero(guardado)
+	return true
+}
+
+// Devuelve si hay que saltar y a qué PC
+func evaluarSalto(proceso *globalsCpu.Proceso, cod_op string, variables []string) (bool, int, bool) {
+	if len(variables) == 0 {
+		return false, 0, false
+	}
+	destino, err := strconv.Atoi(variables[len(variables)-1])
+	if err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("%s: destino inválido %s", cod_op, variables[len(variables)-1]))
+		return false, 0, false
+	}
+
+	esCero := proceso.Registros.Cero()
+	if len(variables) == 2 {
+		valor, ok := proceso.Registros.Obtener(variables[0])
+		if !ok {
+			clientUtils.Logger.Error(fmt.Sprintf("%s: registro inválido %s", cod_op, variables[0]))
+			return false, 0, false
+		}
+		esCero = valor == 0
+	}
+
+	if cod_op == JZ {
+		return esCero, destino, true
+	}
+	return !esCero, destino, true
 }
 
 func Syscall(proceso *globalsCpu.Proceso, cod_op string, variables []string) {
 	switch cod_op {
 	case IO:
 		clientUtils.Logger.Info("## Llamar al sistema para ejecutar IO")
-		LimpiarProceso(proceso.Pid)
 		proceso.Pc++
-		EnviarResultadoAKernel(proceso.Pc, cod_op, variables)
+		devolverAlKernel(proceso, cod_op, variables)
 		return
 	case INIT_PROC:
 		clientUtils.Logger.Info("## Llamar al sistema para ejecutar INIT_PROC")
-		LimpiarProceso(proceso.Pid)
 		proceso.Pc++
-		EnviarResultadoAKernel(proceso.Pc, cod_op, variables)
+		devolverAlKernel(proceso, cod_op, variables)
+		return
+	case FORK:
+		clientUtils.Logger.Info("## Llamar al sistema para ejecutar FORK")
+		proceso.Pc++
+		devolverAlKernel(proceso, cod_op, variables)
 		return
 	case DUMP_MEMORY:
 		clientUtils.Logger.Info("## Llamar al sistema para ejecutar DUMP_MEMORY")
-		LimpiarProceso(proceso.Pid)
 		proceso.Pc++
-		EnviarResultadoAKernel(proceso.Pc, cod_op, variables)
+		devolverAlKernel(proceso, cod_op, variables)
 		return
 	case EXIT:
 		clientUtils.Logger.Info("## Llamar al sistema para ejecutar EXIT")
-		LimpiarProceso(proceso.Pid)
-		EnviarResultadoAKernel(proceso.Pc, cod_op, variables)
+		devolverAlKernel(proceso, cod_op, variables)
+		return
+	case KILL:
+		clientUtils.Logger.Info("## Llamar al sistema para ejecutar KILL")
+		proceso.Pc++
+		devolverAlKernel(proceso, cod_op, variables)
+		return
+	case WAIT_PID:
+		clientUtils.Logger.Info("## Llamar al sistema para ejecutar WAIT_PID")
+		proceso.Pc++
+		devolverAlKernel(proceso, cod_op, variables)
+		return
+	case BRK:
+		clientUtils.Logger.Info("## Llamar al sistema para ejecutar BRK")
+		// El tamaño puede venir en un registro. Si no se puede resolver se manda tal cual y el Kernel lo rechaza
+		if len(variables) == 1 {
+			if tamanio, ok := valorOperando(proceso, variables[0]); ok {
+				variables = []string{strconv.FormatUint(uint64(tamanio), 10)}
+			}
+		}
+		proceso.Pc++
+		// Se baja la caché antes de que Memoria saque páginas del proceso
+		devolverAlKernel(proceso, cod_op, variables)
+		return
+	case WAIT, SIGNAL, MUTEX_LOCK, MUTEX_UNLOCK, SHM_CREATE, SHM_ATTACH, SHM_DETACH:
+		clientUtils.Logger.Info(fmt.Sprintf("## Llamar al sistema para ejecutar %s", cod_op))
+		proceso.Pc++
+		devolverAlKernel(proceso, cod_op, variables)
 		return
 	default:
 		clientUtils.Logger.Error("Error, instruccion no reconocida")
@@ -383,261 +677,540 @@ func Syscall(proceso *globalsCpu.Proceso, cod_op string, variables []string) {
 	}
 }
 
+// FORK registro: el Kernel responde el PID del hijo, o -1 si no lo pudo crear. El hijo arranca
+// con el mismo contexto pero con 0 en ese registro
+func asignarResultadoFork(proceso *globalsCpu.Proceso, registro string, respuesta string) {
+	pidHijo, err := strconv.Atoi(respuesta)
+	if err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("FORK: respuesta inválida del Kernel: %s", respuesta))
+		return
+	}
+	proceso.Registros.Asignar(registro, uint32(pidHijo))
+	if pidHijo < 0 {
+		clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - FORK no pudo crear el proceso hijo", proceso.Pid))
+		return
+	}
+	clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - FORK creó al proceso %d", proceso.Pid, pidHijo))
+}
+
+// BRK tamaño: el Kernel responde el nuevo tamaño, o -1 si Memoria no pudo cambiarlo. Si el tamaño
+// vino en un registro, el resultado queda en ese registro para que el programa lo pueda consultar
+func asignarResultadoBrk(proceso *globalsCpu.Proceso, variables []string, respuesta string) {
+	tamanio, err := strconv.Atoi(respuesta)
+	if err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("BRK: respuesta inválida del Kernel: %s", respuesta))
+		return
+	}
+	if len(variables) == 1 {
+		proceso.Registros.Asignar(variables[0], uint32(tamanio))
+	}
+	if tamanio < 0 {
+		clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - BRK no pudo cambiar el tamaño del proceso", proceso.Pid))
+		return
+	}
+	clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - BRK cambió el tamaño del proceso a %d bytes", proceso.Pid, tamanio))
+}
+
 // Escribir y Leer memoria
 // 1-Bucar el nro de pagina
 // 2-Buscar en la cache si existe
 // 3-Si no existe, buscar en la tlb
 // 4-Si no existe en la tlb, buscar en memoria
 // 5-Escribir o leer el contenido
-func readMemoria(pid int, direccionLogica int, tamanio int) {
-	pagina := mmuUtils.ObtenerNumeroDePagina(direccionLogica)
-	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
-
-	if globalsCpu.CpuConfig.CacheEntries > 0 {
-		contenido, encontroContenido := cacheUtils.BuscarPaginaEnCache(pid, pagina)
-		if encontroContenido {
-			contenidoStr := string(contenido)
-			if desplazamiento+tamanio <= len(contenidoStr) {
-				fmt.Println(contenidoStr[desplazamiento : desplazamiento+tamanio])
-			} else {
-				clientUtils.Logger.Error("READ - Error: el rango solicitado excede el contenido en caché")
-				fmt.Println(contenidoStr[desplazamiento:])
-			}
-			return
-		} else {
-			//clientUtils.Logger.Info(fmt.Sprintf("READ - PID: %d, Página %d → Cache MISS", pid, pagina))
-		}
-	}
-
-	marco, err := mmuUtils.ObtenerMarco(pid, direccionLogica)
+func readMemoria(pid int, direccionLogica int, tamanio int) error {
+	contenido, err := leerMemoria(pid, direccionLogica, tamanio)
 	if err != nil {
-		clientUtils.Logger.Error(fmt.Sprintf("READ - Error al obtener marco: %s", err))
-		return
+		clientUtils.Logger.Error(fmt.Sprintf("READ - Error: %s", err))
+		return err
 	}
+	fmt.Println(string(contenido))
+	return nil
+}
 
-	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Página: %d - Marco: %d", pid, pagina, marco))
-
-	contenido, err := consultaRead(pid, marco, direccionLogica, tamanio)
-
+func writeMemoria(pid int, direccionLogica int, dato string) error {
+	err := escribirMemoria(pid, direccionLogica, []byte(dato))
 	if err != nil {
-		clientUtils.Logger.Error(fmt.Sprintf("READ - Error al consultar memoria: %s", err))
-		return
+		clientUtils.Logger.Error(fmt.Sprintf("WRITE - Error: %s", err))
 	}
+	return err
+}
 
-	if globalsCpu.CpuConfig.CacheEntries > 0 {
-		cacheUtils.AgregarACache(pid, direccionLogica, contenido, false)
-		clientUtils.Logger.Info(fmt.Sprintf("READ - PID: %d, Página %d → Agregando a caché", pid, pagina))
+// Devuelve true si el acceso falló y el proceso ya se devolvió al Kernel, por una falla de
+// memoria o por cualquier otro error que no deja completar la instrucción
+func atenderErrorDeMemoria(proceso *globalsCpu.Proceso, err error) bool {
+	if err == nil {
+		return false
 	}
-
-	if tamanio > len(contenido) {
-		clientUtils.Logger.Error("READ - Error, el tamaño a leer es mayor a su contenido, se devolverá el contenido completo")
-		fmt.Println(string(contenido))
-		return
+	if !atenderFallaDeMemoria(proceso, err) {
+		devolverConError(proceso, err.Error())
 	}
-
-	fmt.Println(string(contenido[:tamanio]))
+	return true
 }
 
-func writeMemoria(pid int, direccionLogica int, dato string) {
-	// Traducir dirección lógica a física
-	pagina := mmuUtils.ObtenerNumeroDePagina(direccionLogica)
+// Si el acceso falló porque la página no está en memoria, devuelve el proceso al Kernel con
+// PAGE_FAULT sin avanzar el PC: cuando vuelva a ejecutar se reintenta la misma instrucción.
+// Si estaba fuera del proceso o sin permiso lo devuelve con SEG_FAULT para que lo finalice
+func atenderFallaDeMemoria(proceso *globalsCpu.Proceso, err error) bool {
+	var falla *mmuUtils.FallaDePagina
+	if errors.As(err, &falla) {
+		clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - PAGE FAULT - Página: %d", proceso.Pid, falla.Pagina))
+		devolverAlKernel(proceso, PAGE_FAULT, []string{strconv.Itoa(falla.Pagina)})
+		return true
+	}
+	var segmentacion *mmuUtils.FallaDeSegmentacion
+	if errors.As(err, &segmentacion) {
+		clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - SEG FAULT - Dirección: %d - %s", proceso.Pid, segmentacion.Direccion, segmentacion.Motivo))
+		devolverAlKernel(proceso, SEG_FAULT, []string{strconv.Itoa(segmentacion.Direccion), segmentacion.Motivo})
+		return true
+	}
+	return false
+}
 
-	if globalsCpu.CpuConfig.CacheEntries != 0 {
-		_, encontroDato := cacheUtils.BuscarPaginaEnCache(pid, pagina)
-		if encontroDato {
-			//Logs en buscarPaginaEnCache
-			//clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Cache HIT - Página %d, Contenido %s", pid, pagina, dato))
-			err := cacheUtils.ModificarContenidoCache(pid, pagina, dato, direccionLogica)
-			if err != nil {
-				clientUtils.Logger.Error(fmt.Sprintf("WRITE - Error al modificar contenido en cache: %s", err))
-				return
-			} else {
-				return
-			}
-		} else {
-			//clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Cache MISS - Página %d", pid, pagina))
-			cacheUtils.AgregarACache(pid, direccionLogica, []byte(dato), true)
-			return
+// Lee tamanio bytes desde la dirección lógica, partiendo el acceso en cada límite de página
+func leerMemoria(pid int, direccionLogica int, tamanio int) ([]byte, error) {
+	contenido := make([]byte, 0, tamanio)
+	for leidos := 0; leidos < tamanio; {
+		direccion := direccionLogica + leidos
+		enPagina := min(tamanio-leidos, globalsCpu.Memoria.TamanioPagina-mmuUtils.ObtenerDesplazamiento(direccion))
+		parte, err := leerEnPagina(pid, direccion, enPagina)
+		if err != nil {
+			return nil, err
 		}
+		contenido = append(contenido, parte...)
+		leidos += enPagina
 	}
+	return contenido, nil
+}
 
-	marco, err := mmuUtils.ObtenerMarco(pid, direccionLogica)
-	if err != nil {
-		clientUtils.Logger.Error(fmt.Sprintf("WRITE - Error al obtener marco: %s", err))
-		return
+// Escribe los datos desde la dirección lógica, partiendo el acceso en cada límite de página
+func escribirMemoria(pid int, direccionLogica int, datos []byte) error {
+	for escritos := 0; escritos < len(datos); {
+		direccion := direccionLogica + escritos
+		enPagina := min(len(datos)-escritos, globalsCpu.Memoria.TamanioPagina-mmuUtils.ObtenerDesplazamiento(direccion))
+		if err := escribirEnPagina(pid, direccion, datos[escritos:escritos+enPagina]); err != nil {
+			return err
+		}
+		escritos += enPagina
 	}
+	return nil
+}
 
-	consultaWrite(pid, marco, direccionLogica, []byte(dato))
+// Lectura que no cruza de página: primero la caché, si no está se trae la página
+// (o se va directo a Memoria si no hay caché)
+func leerEnPagina(pid int, direccionLogica int, tamanio int) ([]byte, error) {
+	pagina := mmuUtils.ObtenerNumeroDePagina(direccionLogica)
+	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
 
-	//Log
-	//clientUtils.Logger.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Página: %d - Marco: %d", proceso.Pid, pagina, marco))
+	// Se traduce una sola vez, también si la página está en la caché: recién con la traducción se
+	// sabe si es de un segmento compartido, y su marco sirve para traerla a la caché o leer de Memoria.
+	// Como en la escritura, se traduce el último byte para que Memoria controle la lectura entera
+	marco, err := mmuUtils.ObtenerMarco(pid, direccionLogica+tamanio-1)
+	if err != nil {
+		return nil, fmt.Errorf("al obtener marco: %w", err)
+	}
 
-	if globalsCpu.CpuConfig.CacheEntries > 0 {
-		// Agregar a la caché
-		cacheUtils.AgregarACache(pid, direccionLogica, []byte(dato), true)
-		clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Cache Add - Página %d", pid, pagina))
+	// Las páginas de segmentos compartidos no entran en la caché, se leen siempre de Memoria
+	if globalsCpu.CpuConfig.CacheEntries > 0 && !mmuUtils.EsPaginaCompartida(pid, pagina) {
+		contenido, encontro := cacheUtils.BuscarPaginaEnCache(pid, pagina)
+		if !encontro {
+			cacheUtils.AgregarACache(pid, direccionLogica, marco, []byte{}, false)
+			contenido, encontro = cacheUtils.ContenidoCargado(pid, pagina)
+		}
+		if encontro && desplazamiento+tamanio <= len(contenido) {
+			return append([]byte{}, contenido[desplazamiento:desplazamiento+tamanio]...), nil
+		}
+		clientUtils.Logger.Warn(fmt.Sprintf("PID: %d - No se pudo leer la página %d desde caché, se lee de Memoria", pid, pagina))
 	}
 
+	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Página: %d - Marco: %d", pid, pagina, marco))
+
+	return consultaRead(pid, marco, direccionLogica, tamanio)
 }
 
-func consultaWrite(pid int, marco int, direccionLogica int, datos []byte) error {
-	pageSize := globalsCpu.Memoria.TamanioPagina
-	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
+// Escritura que no cruza de página. Con caché y write-back se escribe ahí y Memoria se actualiza al
+// desalojarla. Con write-through, o con no-write-allocate y la página fuera de la caché, la escritura
+// además va a Memoria por el mismo camino que sin caché. Las páginas de segmentos compartidos no
+// entran en la caché y siempre se escriben directo
+func escribirEnPagina(pid int, direccionLogica int, datos []byte) error {
+	pagina := mmuUtils.ObtenerNumeroDePagina(direccionLogica)
 
-	if len(datos) == 1 {
-		valores := []string{
-			strconv.Itoa(pid),
-			strconv.Itoa(marco*pageSize + desplazamiento),
-			strconv.Itoa(int(datos[0])),
+	// Toda escritura se traduce para escribir, también la que cae en la caché: si la página no lo
+	// permite falla ahora y no al bajarla, y una copy-on-write recibe su marco antes de ensuciarse.
+	// Se traduce el último byte para que Memoria controle que la escritura entera es del proceso
+	marco, err := mmuUtils.ObtenerMarcoParaEscritura(pid, direccionLogica+len(datos)-1)
+	if err != nil {
+		return fmt.Errorf("al obtener marco: %w", err)
+	}
+
+	if globalsCpu.CpuConfig.CacheEntries > 0 && !mmuUtils.EsPaginaCompartida(pid, pagina) {
+		inmediata := cacheUtils.EscrituraInmediata()
+		if _, encontro := cacheUtils.BuscarPaginaEnCache(pid, pagina); encontro {
+			if err := cacheUtils.ModificarContenidoCache(pid, pagina, string(datos), direccionLogica, marco, !inmediata); err != nil {
+				return err
+			}
+		} else if !cacheUtils.AsignaEnEscritura() || !cacheUtils.AgregarACache(pid, direccionLogica, marco, datos, !inmediata) {
+			inmediata = true
+		}
+		cacheUtils.RegistrarEscritura(inmediata)
+		if !inmediata {
+			return nil
 		}
-		paquete := clientUtils.Paquete{Valores: valores}
+	}
 
-		clientUtils.Logger.Info(fmt.Sprintf("“PID: %d - Acción: Escribir - Dirección Física: %d - Valor: %s.", pid, marco*pageSize+desplazamiento, string(datos[0])))
+	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - OBTENER MARCO - Página: %d - Marco: %d", pid, pagina, marco))
 
-		respuesta := clientUtils.EnviarPaqueteConRespuestaBody(
-			globalsCpu.CpuConfig.IpMemory,
-			globalsCpu.CpuConfig.PortMemory,
-			"writeMemoria",
-			paquete,
-		)
+	return consultaWrite(pid, marco, direccionLogica, datos)
+}
 
-		if respuesta == nil {
-			return fmt.Errorf("error al escribir valor en memoria")
-		}
+// MOV_IN registroDatos registroDirección: carga en el registro 1 o 4 bytes de memoria (little endian)
+func movIn(proceso *globalsCpu.Proceso, registroDatos string, registroDireccion string) error {
+	direccion, ok := proceso.Registros.Obtener(registroDireccion)
+	tamanio := registros.Tamanio(registroDatos)
+	if !ok || tamanio == 0 {
+		return fmt.Errorf("MOV_IN: registros inválidos %s %s", registroDatos, registroDireccion)
+	}
 
-		return nil
+	datos, err := leerMemoria(proceso.Pid, int(direccion), tamanio)
+	if err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("MOV_IN - Error al leer memoria: %s", err))
+		return err
 	}
 
-	if desplazamiento+len(datos) > pageSize {
-		return fmt.Errorf("datos a escribir exceden tamaño de página")
+	var valor uint32
+	for i := tamanio - 1; i >= 0; i-- {
+		valor = valor<<8 | uint32(datos[i])
 	}
+	proceso.Registros.Asignar(registroDatos, valor)
+	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: LEER - Dirección Lógica: %d - Valor: %d", proceso.Pid, direccion, valor))
+	return nil
+}
 
-	// Primero leemos toda la página para modificar solo los bytes necesarios
-	valoresLeer := []string{
-		strconv.Itoa(pid),
-		strconv.Itoa(marco),
-		strconv.Itoa(pageSize),
+// MOV_OUT registroDirección registroDatos: guarda en memoria los 1 o 4 bytes del registro (little endian)
+func movOut(proceso *globalsCpu.Proceso, registroDireccion string, registroDatos string) error {
+	direccion, okDireccion := proceso.Registros.Obtener(registroDireccion)
+	valor, okDatos := proceso.Registros.Obtener(registroDatos)
+	if !okDireccion || !okDatos {
+		return fmt.Errorf("MOV_OUT: registros inválidos %s %s", registroDireccion, registroDatos)
 	}
-	paqueteLeer := clientUtils.Paquete{Valores: valoresLeer}
 
-	paginaCompleta := clientUtils.EnviarPaqueteConRespuestaBody(
-		globalsCpu.CpuConfig.IpMemory,
-		globalsCpu.CpuConfig.PortMemory,
-		"readPagina",
-		paqueteLeer,
-	)
+	datos := make([]byte, registros.Tamanio(registroDatos))
+	for i := range datos {
+		datos[i] = byte(valor >> (8 * i))
+	}
+	if err := escribirMemoria(proceso.Pid, int(direccion), datos); err != nil {
+		clientUtils.Logger.Error(fmt.Sprintf("MOV_OUT - Error al escribir memoria: %s", err))
+		return err
+	}
+	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: ESCRIBIR - Dirección Lógica: %d - Valor: %d", proceso.Pid, direccion, valor))
+	return nil
+}
 
-	clientUtils.Logger.Info(fmt.Sprintf("“PID: %d - Acción: Leer - Dirección Física: %d - Valor leído: %s.", pid, marco*pageSize, paginaCompleta))
+// Manda solo los bytes modificados: si se reescribiera la página entera se pisarían las escrituras
+// que otro proceso hizo mientras tanto en un segmento compartido
+func consultaWrite(pid int, marco int, direccionLogica int, datos []byte) error {
+	pageSize := globalsCpu.Memoria.TamanioPagina
+	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
 
-	if len(paginaCompleta) != pageSize {
-		return fmt.Errorf("no se pudo leer página completa antes de escribir")
+	if desplazamiento+len(datos) > pageSize {
+		return fmt.Errorf("datos a escribir exceden tamaño de página")
 	}
 
-	// Modificar solo el rango correspondiente
-	copy(paginaCompleta[desplazamiento:], datos)
-
-	// Preparar paquete para escribir página completa
 	valores := []string{
 		strconv.Itoa(pid),
-		strconv.Itoa(marco),
-		strconv.Itoa(pageSize),
+		strconv.Itoa(marco*pageSize + desplazamiento),
 	}
-	for _, b := range paginaCompleta {
+	for _, b := range datos {
 		valores = append(valores, strconv.Itoa(int(b)))
 	}
 	paquete := clientUtils.Paquete{Valores: valores}
 
-	var bytesAEnviar []byte
-	for _, v := range paquete.Valores[3:] {
-		n, err := strconv.Atoi(v)
-		if err != nil {
-			// Si algo falla lo reemplazo por un '?'
-			bytesAEnviar = append(bytesAEnviar, '?')
-			continue
-		}
-		bytesAEnviar = append(bytesAEnviar, byte(n))
-	}
+	clientUtils.Logger.Info(fmt.Sprintf("“PID: %d - Acción: Escribir - Dirección Física: %d - Valor: %s.", pid, marco*pageSize+desplazamiento, string(datos)))
 
-	clientUtils.Logger.Info(fmt.Sprintf("PID: %d - Acción: Escribir - Dirección Física: %d - Valor: %s", pid, marco*pageSize, string(bytesAEnviar)))
-
-	clientUtils.EnviarPaquete(
+	respuesta := clientUtils.EnviarPaqueteConRespuestaBody(
 		globalsCpu.CpuConfig.IpMemory,
 		globalsCpu.CpuConfig.PortMemory,
-		"writePagina",
+		"writeMemoria",
 		paquete,
 	)
 
-	return nil
+	if respuesta == nil {
+		return fmt.Errorf("error al escribir valor en memoria")
+	}
+
+	return mmuUtils.FallaEnRespuesta(respuesta, direccionLogica)
 }
 
+// Lee de Memoria solo los bytes pedidos, así Memoria controla que el rango entero sea del proceso
 func consultaRead(pid int, marco int, direccionLogica int, tamanio int) ([]byte, error) {
 	pageSize := globalsCpu.Memoria.TamanioPagina
 	desplazamiento := mmuUtils.ObtenerDesplazamiento(direccionLogica)
 
-	if tamanio == 1 {
-		valores := []string{
-			strconv.Itoa(pid),
-			strconv.Itoa(marco*pageSize + desplazamiento),
-		}
-		paquete := clientUtils.Paquete{Valores: valores}
-
-		respuesta := clientUtils.EnviarPaqueteConRespuestaBody(
-			globalsCpu.CpuConfig.IpMemory,
-			globalsCpu.CpuConfig.PortMemory,
-			"readMemoria",
-			paquete,
-		)
-
-		if respuesta == nil {
-			return nil, fmt.Errorf("valor recibido nulo")
-		}
-
-		clientUtils.Logger.Info(fmt.Sprintf("“PID: %d - Acción: Leer - Dirección Física: %d - Valor leído: %s.", pid, marco*pageSize+desplazamiento, string(respuesta)))
-
-		return respuesta, nil
+	if desplazamiento+tamanio > pageSize {
+		return nil, fmt.Errorf("rango de lectura excede tamaño de página")
 	}
 
-	// Armar paquete para leer página completa
 	valores := []string{
 		strconv.Itoa(pid),
-		strconv.Itoa(marco),
-		strconv.Itoa(pageSize),
+		strconv.Itoa(marco*pageSize + desplazamiento),
+		strconv.Itoa(tamanio),
 	}
 	paquete := clientUtils.Paquete{Valores: valores}
 
 	respuesta := clientUtils.EnviarPaqueteConRespuestaBody(
 		globalsCpu.CpuConfig.IpMemory,
 		globalsCpu.CpuConfig.PortMemory,
-		"readPagina",
+		"readMemoria",
 		paquete,
 	)
 
-	clientUtils.Logger.Info(fmt.Sprintf("“PID: %d - Acción: Leer - Dirección Física: %d - Valor leído: %s.", pid, marco*pageSize, respuesta[desplazamiento:desplazamiento+tamanio]))
-
-	if len(respuesta) != pageSize {
-		clientUtils.Logger.Error(fmt.Sprintf("READ - Tamaño de página recibido incorrecto: esperado %d, recibido %d", pageSize, len(respuesta)))
-		return nil, fmt.Errorf("tamaño de página recibido incorrecto")
+	if respuesta == nil {
+		return nil, fmt.Errorf("valor recibido nulo")
 	}
-
-	// Luego, retornar sólo los bytes solicitados (con desplazamiento)
-	if desplazamiento+tamanio > pageSize {
-		return nil, fmt.Errorf("rango de lectura excede tamaño de página")
+	if err := mmuUtils.FallaEnRespuesta(respuesta, direccionLogica); err != nil {
+		return nil, err
 	}
+	if len(respuesta) != tamanio {
+		clientUtils.Logger.Error(fmt.Sprintf("READ - Cantidad de bytes recibida incorrecta: esperado %d, recibido %d", tamanio, len(respuesta)))
+		return nil, fmt.Errorf("cantidad de bytes recibida incorrecta")
+	}
+
+	clientUtils.Logger.Info(fmt.Sprintf("“PID: %d - Acción: Leer - Dirección Física: %d - Valor leído: %s.", pid, marco*pageSize+desplazamiento, string(respuesta)))
 
-	return respuesta[desplazamiento : desplazamiento+tamanio], nil
+	return respuesta, nil
 }
 
 //-----------------------------
 
-func LimpiarProceso(pid int) {
-	//Paso los datos de la cache que fueron modificados a memoria
-	// Luego limpio el cache y luego la TLB
+func LimpiarProceso(pid int) error {
+	//Paso los datos de la cache que fueron modificados a memoria, eso ya vacía la caché
+	// salvo lo que no se pudo bajar. Luego limpio la TLB
+	var err error
 	if globalsCpu.CpuConfig.CacheEntries != 0 {
 		//clientUtils.Logger.Info("Cache detectada, se flushearan las paginas modificadas a la memoria")
-		cacheUtils.FlushPaginasModificadas(pid)
-		cacheUtils.LimpiarCache()
+		err = cacheUtils.FlushPaginasModificadas(pid)
 	}
-	if globalsCpu.CpuConfig.TlbEntries != 0 {
+	// Con ASID las entradas quedan para la próxima vez que ejecute, Memoria avisa las que dejan de valer
+	if globalsCpu.CpuConfig.TlbEntries != 0 && !globalsCpu.CpuConfig.TlbAsid {
 		tlbUtils.LimpiarTLB()
 	}
+	return err
+}
+
+// Memoria manda ["PID", "PAGINA"...] cuando esas páginas dejan su marco (o ["PID"] para todas las
+// del proceso). Solo le avisa a las CPUs que se registraron por tener tlb_asid
+func InvalidarTLB(w http.ResponseWriter, r *http.Request) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 1 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, err := strconv.Atoi(paquete.Valores[0])
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	var paginas []int
+	for _, valor := range paquete.Valores[1:] {
+		pagina, err := strconv.Atoi(valor)
+		if err != nil {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		paginas = append(paginas, pagina)
+	}
+
+	tlbUtils.Invalidar(pid, paginas)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Métricas de la CPU: contadores de la TLB y de escrituras de la caché
+type MetricasCPU struct {
+	Tlb   tlbUtils.MetricasTLB     `json:"tlb"`
+	Cache cacheUtils.MetricasCache `json:"cache"`
+}
+
+func Metricas(w http.ResponseWriter, r *http.Request) {
+	respuesta, err := json.Marshal(MetricasCPU{Tlb: tlbUtils.Metricas(), Cache: cacheUtils.Metricas()})
+	if err != nil {
+		http.Error(w, "Error al codificar las métricas", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respuesta)
+}
+
+//----------------------------------------------------------------------
+// Debugger: breakpoints por PID/PC, pausa, paso a paso e inspección del proceso pausado
+
+// Vista del estado de la CPU que devuelve /debug/estado
+type EstadoDebug struct {
+	Pausado     bool                    `json:"pausado"`
+	Proceso     *globalsCpu.Proceso     `json:"proceso,omitempty"`
+	Breakpoints []debugger.Breakpoint   `json:"breakpoints"`
+	Tlb         []globalsCpu.EntradaTLB `json:"tlb"`
+	Cache       []EntradaCacheDebug     `json:"cache"`
+}
+
+type EntradaCacheDebug struct {
+	Pid        int    `json:"pid"`
+	Pagina     int    `json:"pagina"`
+	Contenido  string `json:"contenido"`
+	Uso        bool   `json:"uso"`
+	Modificado bool   `json:"modificado"`
+}
+
+// Recibe ["PID", "PC"] y agrega el breakpoint
+func DebugAgregarBreakpoint(w http.ResponseWriter, r *http.Request) {
+	pid, pc, ok := recibirBreakpoint(w, r)
+	if !ok {
+		return
+	}
+	debugger.AgregarBreakpoint(pid, pc)
+	clientUtils.Logger.Info(fmt.Sprintf("## Debugger - Breakpoint agregado - PID: %d - PC: %d", pid, pc))
+	w.WriteHeader(http.StatusOK)
+}
+
+// Recibe ["PID", "PC"] y quita el breakpoint
+func DebugQuitarBreakpoint(w http.ResponseWriter, r *http.Request) {
+	pid, pc, ok := recibirBreakpoint(w, r)
+	if !ok {
+		return
+	}
+	if !debugger.QuitarBreakpoint(pid, pc) {
+		http.Error(w, "Breakpoint inexistente", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func recibirBreakpoint(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	pid, errPid := strconv.Atoi(paquete.Valores[0])
+	pc, errPc := strconv.Atoi(paquete.Valores[1])
+	if errPid != nil || errPc != nil || pid < 0 || pc < 0 {
+		http.Error(w, "PID o PC inválido", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return pid, pc, true
+}
+
+func DebugPausar(w http.ResponseWriter, r *http.Request) {
+	debugger.Pausar()
+	w.WriteHeader(http.StatusOK)
+}
+
+func DebugPaso(w http.ResponseWriter, r *http.Request) {
+	if !debugger.Paso() {
+		http.Error(w, "No hay ningún proceso pausado", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func DebugContinuar(w http.ResponseWriter, r *http.Request) {
+	debugger.Continuar()
+	w.WriteHeader(http.StatusOK)
+}
+
+func DebugEstado(w http.ResponseWriter, r *http.Request) {
+	estado := EstadoDebug{Breakpoints: debugger.Breakpoints()}
+	if proceso, ok := debugger.Pausado(); ok {
+		estado.Pausado = true
+		estado.Proceso = &proceso
+	}
+
+	estado.Tlb = tlbUtils.Entradas()
+
+	globalsCpu.CacheMutex.Lock()
+	estado.Cache = make([]EntradaCacheDebug, 0, len(globalsCpu.Cache))
+	for _, entrada := range globalsCpu.Cache {
+		estado.Cache = append(estado.Cache, EntradaCacheDebug{
+			Pid:        entrada.Pid,
+			Pagina:     entrada.Pagina,
+			Contenido:  fmt.Sprintf("% x", entrada.Contenido),
+			Uso:        entrada.Uso,
+			Modificado: entrada.Modificado,
+		})
+	}
+	globalsCpu.CacheMutex.Unlock()
+
+	respuesta, err := json.Marshal(estado)
+	if err != nil {
+		http.Error(w, "Error al codificar el estado", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respuesta)
+}
+
+// Recibe ["DIRECCION_LOGICA", "TAMANIO"] y lee la memoria del proceso pausado a través de la MMU
+// Lectura del debugger: usa la página si ya está en la caché y si no la traduce directo con Memoria,
+// sin pasar por la TLB ni traerla a la caché. Así inspeccionar no cambia reemplazos ni contadores
+func leerParaInspeccion(pid int, direccionLogica int, tamanio int) ([]byte, error) {
+	contenido := make([]byte, 0, tamanio)
+	for leidos := 0; leidos < tamanio; {
+		direccion := direccionLogica + leidos
+		desplazamiento := mmuUtils.ObtenerDesplazamiento(direccion)
+		enPagina := min(tamanio-leidos, globalsCpu.Memoria.TamanioPagina-desplazamiento)
+
+		pagina, enCache := cacheUtils.ContenidoCargado(pid, mmuUtils.ObtenerNumeroDePagina(direccion))
+		if enCache && desplazamiento+enPagina <= len(pagina) {
+			contenido = append(contenido, pagina[desplazamiento:desplazamiento+enPagina]...)
+		} else {
+			marco, err := mmuUtils.ObtenerMarcoMultinivel(pid, direccion, globalsCpu.Memoria.NivelesPaginacion, globalsCpu.Memoria.CantidadEntradas, false)
+			if err != nil {
+				return nil, err
+			}
+			parte, err := consultaRead(pid, marco, direccion, enPagina)
+			if err != nil {
+				return nil, err
+			}
+			contenido = append(contenido, parte...)
+		}
+		leidos += enPagina
+	}
+	return contenido, nil
+}
+
+func DebugLeerMemoria(w http.ResponseWriter, r *http.Request) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	direccion, errDireccion := strconv.Atoi(paquete.Valores[0])
+	tamanio, errTamanio := strconv.Atoi(paquete.Valores[1])
+	if errDireccion != nil || errTamanio != nil || direccion < 0 || tamanio <= 0 {
+		http.Error(w, "Dirección o tamaño inválido", http.StatusBadRequest)
+		return
+	}
+
+	// Solo con el proceso pausado: el ciclo de instrucción no está usando la TLB ni la caché
+	proceso, ok := debugger.Pausado()
+	if !ok {
+		http.Error(w, "No hay ningún proceso pausado", http.StatusConflict)
+		return
+	}
+
+	datos, err := leerParaInspeccion(proceso.Pid, direccion, tamanio)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	respuesta, _ := json.Marshal(map[string]any{
+		"pid":       proceso.Pid,
+		"direccion": direccion,
+		"hex":       fmt.Sprintf("% x", datos),
+		"texto":     string(datos),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respuesta)
 }
@@ -0,0 +1,160 @@
+package debugger
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	globalsCpu "github.com/sisoputnfrba/tp-golang/cpu/globalsCpu"
+	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
+)
+
+// Qué tiene que hacer el ciclo de instrucción después de pasar por el debugger
+type Resultado int
+
+const (
+	SEGUIR       Resultado = iota // ejecutar la instrucción del PC actual
+	CANCELADO                     // llegó otro proceso a la CPU, se abandona el actual
+	INTERRUMPIDO                  // llegó una interrupción del Kernel estando en pausa, hay que atenderla
+)
+
+// Cada cuánto se revisa si llegó una interrupción mientras el proceso está pausado
+const intervaloInterrupciones = 50 * time.Millisecond
+
+// Breakpoint en un PC de un proceso
+type Breakpoint struct {
+	Pid int `json:"pid"`
+	Pc  int `json:"pc"`
+}
+
+var (
+	mu           sync.Mutex
+	breakpoints  = map[Breakpoint]bool{}
+	pausaPedida  bool                // frenar en la próxima instrucción, sea del proceso que sea
+	pidPasoAPaso = -1                // proceso que vuelve a frenar después de cada instrucción, -1 ninguno
+	pausado      *globalsCpu.Proceso // proceso frenado, nil si la CPU está ejecutando
+	despertar    chan struct{}       // se cierra para que el proceso pausado siga
+)
+
+// Esperar se llama antes de cada FETCH. Si hay que frenar en este PC bloquea hasta que se pida
+// continuar o dar un paso, hasta que llegue otro proceso o hasta que el Kernel mande una interrupción
+// (así un desalojo o una finalización nunca quedan esperando al debugger).
+func Esperar(ctx context.Context, proceso *globalsCpu.Proceso) Resultado {
+	mu.Lock()
+	if !pausaPedida && pidPasoAPaso != proceso.Pid && !breakpoints[Breakpoint{proceso.Pid, proceso.Pc}] {
+		mu.Unlock()
+		return SEGUIR
+	}
+	pausaPedida = false
+	pausado = proceso
+	despertar = make(chan struct{})
+	seguir := despertar
+	mu.Unlock()
+
+	clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - Pausado por el debugger - Program Counter: %d", proceso.Pid, proceso.Pc))
+
+	defer func() {
+		mu.Lock()
+		pausado = nil
+		mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(intervaloInterrupciones)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return CANCELADO
+		case <-seguir:
+			return SEGUIR
+		case <-ticker.C:
+			if globalsCpu.Interrupciones.ExisteInterrupcion.Load() {
+				clientUtils.Logger.Info(fmt.Sprintf("## PID: %d - Interrupción recibida durante la pausa del debugger", proceso.Pid))
+				return INTERRUMPIDO
+			}
+		}
+	}
+}
+
+func AgregarBreakpoint(pid int, pc int) {
+	mu.Lock()
+	defer mu.Unlock()
+	breakpoints[Breakpoint{pid, pc}] = true
+}
+
+func QuitarBreakpoint(pid int, pc int) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if !breakpoints[Breakpoint{pid, pc}] {
+		return false
+	}
+	delete(breakpoints, Breakpoint{pid, pc})
+	return true
+}
+
+func Breakpoints() []Breakpoint {
+	mu.Lock()
+	defer mu.Unlock()
+	lista := make([]Breakpoint, 0, len(breakpoints))
+	for breakpoint := range breakpoints {
+		lista = append(lista, breakpoint)
+	}
+	sort.Slice(lista, func(i, j int) bool {
+		if lista[i].Pid != lista[j].Pid {
+			return lista[i].Pid < lista[j].Pid
+		}
+		return lista[i].Pc < lista[j].Pc
+	})
+	return lista
+}
+
+// Pausar frena la CPU antes de la próxima instrucción que ejecute
+func Pausar() {
+	mu.Lock()
+	defer mu.Unlock()
+	pausaPedida = true
+}
+
+// Paso ejecuta una sola instrucción del proceso pausado y lo vuelve a frenar
+func Paso() bool {
+	return reanudar(true)
+}
+
+// Continuar sigue ejecutando hasta el próximo breakpoint
+func Continuar() bool {
+	return reanudar(false)
+}
+
+func reanudar(unPaso bool) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if pausado == nil {
+		// Continuar sin nada pausado igual cancela una pausa pedida o un paso a paso en curso
+		if !unPaso {
+			pausaPedida = false
+			pidPasoAPaso = -1
+		}
+		return false
+	}
+	// El paso a paso sigue al proceso pausado: si sale de la CPU no frena al próximo que llegue
+	pidPasoAPaso = -1
+	if unPaso {
+		pidPasoAPaso = pausado.Pid
+	}
+	close(despertar)
+	pausado = nil
+	return true
+}
+
+// Pausado devuelve una copia del proceso frenado. Mientras dura la pausa el ciclo de instrucción
+// está bloqueado, así que la TLB, la caché y la memoria del proceso se pueden inspeccionar.
+func Pausado() (globalsCpu.Proceso, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if pausado == nil {
+		return globalsCpu.Proceso{}, false
+	}
+	return *pausado, true
+}
@@ -4,6 +4,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	registros "github.com/sisoputnfrba/tp-golang/utils/registros"
 )
 
 type Config struct {
@@ -15,16 +17,23 @@ type Config struct {
 	PortKernel      int    `json:"port_kernel"`
 	TlbEntries      int    `json:"tlb_entries"`
 	TlbReplacement  string `json:"tlb_replacement"`
+	TlbRandomSeed   int64  `json:"tlb_random_seed"`
+	TlbAsid         bool   `json:"tlb_asid"` // las entradas se buscan por PID y página y no se borran al cambiar de proceso
 	CacheEntries    int    `json:"cache_entries"`
 	CacheReplacment string `json:"cache_replacement"`
 	CacheDelay      int    `json:"cache_delay"`
-	LogLevel        string `json:"log_level"`
+	// WRITE_BACK (por defecto) baja las páginas modificadas al desalojarlas, WRITE_THROUGH escribe en Memoria en cada WRITE
+	CacheWritePolicy string `json:"cache_write_policy"`
+	// Una escritura que no encuentra la página en caché va directo a Memoria sin cargarla
+	CacheNoWriteAllocate bool   `json:"cache_no_write_allocate"`
+	LogLevel             string `json:"log_level"`
 }
 
-// Representa un proceso con su PID y su Program Counter (PC)
+// Representa un proceso con su PID, su Program Counter (PC) y el resto de sus registros
 type Proceso struct {
-	Pid int `json:"pid"`
-	Pc  int `json:"pc"`
+	Pid       int                 `json:"pid"`
+	Pc        int                 `json:"pc"`
+	Registros registros.Registros `json:"registros"`
 }
 
 type CaracteristicasMemoria struct {
@@ -37,22 +46,32 @@ type EntradaTLB struct {
 	Pid             int
 	Pagina          int
 	Marco           int
+	Escritura       bool // la traducción se pidió para escribir, así que Memoria ya resolvió el copy-on-write
 	UltimoUso       time.Time
 	InstanteCargado time.Time
+	Uso             bool // bit de uso para CLOCK
+	Accesos         int  // hits desde que se cargó, para LFU
 }
 
 type EntradaCache struct {
-	Pid        int
-	Pagina     int
-	Contenido  []byte
-	Uso        bool
-	Modificado bool
-	Offset     int
+	Pid             int
+	Pagina          int
+	Contenido       []byte
+	Uso             bool
+	Modificado      bool
+	Marco           int // donde se baja; si está modificada viene de la traducción para escribir
+	Offset          int
+	UltimoUso       time.Time
+	InstanteCargado time.Time
+	Accesos         int
+	Frecuente       bool // 2Q: se volvió a pedir poco después de salir de la cola de recientes
 }
 
 type Interrupcion struct {
 	ExisteInterrupcion atomic.Bool
+	Mutex              sync.Mutex // protege Motivo y Pid
 	Motivo             string
+	Pid                int
 }
 
 var (
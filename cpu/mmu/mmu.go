@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"math"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	globalsCpu "github.com/sisoputnfrba/tp-golang/cpu/globalsCpu"
@@ -11,6 +13,68 @@ import (
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
 )
 
+// Respuesta de Memoria cuando la página no está cargada
+const PAGE_FAULT = "PAGE_FAULT"
+
+// Error de traducción por una página que no está en memoria. El proceso tiene que volver al
+// Kernel para que Memoria la cargue
+type FallaDePagina struct {
+	Pagina int
+}
+
+func (f *FallaDePagina) Error() string {
+	return fmt.Sprintf("page fault en la página %d", f.Pagina)
+}
+
+// Respuesta de Memoria a un acceso fuera del proceso o sin permiso, seguida del motivo
+const SEG_FAULT = "SEG_FAULT"
+
+// Error de un acceso que el proceso no tiene permitido. El proceso vuelve al Kernel, que lo finaliza
+type FallaDeSegmentacion struct {
+	Direccion int
+	Motivo    string
+}
+
+func (f *FallaDeSegmentacion) Error() string {
+	return fmt.Sprintf("segmentation fault en la dirección %d: %s", f.Direccion, f.Motivo)
+}
+
+// Devuelve la falla si Memoria rechazó el acceso a la dirección, o nil si la respuesta es válida
+func FallaEnRespuesta(respuesta []byte, direccionLogica int) error {
+	motivo, esFalla := strings.CutPrefix(strings.TrimSpace(string(respuesta)), SEG_FAULT)
+	if !esFalla {
+		return nil
+	}
+	return &FallaDeSegmentacion{Direccion: direccionLogica, Motivo: strings.TrimSpace(strings.TrimPrefix(motivo, ":"))}
+}
+
+// Marca que Memoria agrega a la traducción de una página de un segmento de memoria compartida
+const COMPARTIDA = "COMPARTIDA"
+
+// Páginas de segmentos compartidos que se tradujeron en esta CPU. Otros procesos escriben esos
+// marcos, así que la caché no las guarda. Como la TLB conserva la traducción, se recuerdan acá
+var (
+	paginasCompartidas      = map[[2]int]bool{}
+	mutexPaginasCompartidas sync.Mutex
+)
+
+// Marca de la traducción de la última página del proceso cuando está usada a medias. No se guarda en
+// la TLB: la CPU no conoce el tamaño del proceso y así Memoria controla cada acceso a esa página
+const PARCIAL = "PARCIAL"
+
+func registrarCompartida(pid int, pagina int) {
+	mutexPaginasCompartidas.Lock()
+	defer mutexPaginasCompartidas.Unlock()
+	paginasCompartidas[[2]int{pid, pagina}] = true
+}
+
+// Si la página es de un segmento compartido. Solo se sabe después de traducirla
+func EsPaginaCompartida(pid int, pagina int) bool {
+	mutexPaginasCompartidas.Lock()
+	defer mutexPaginasCompartidas.Unlock()
+	return paginasCompartidas[[2]int{pid, pagina}]
+}
+
 func ObtenerDireccionLogica(nroPagina int) int {
 	return nroPagina * globalsCpu.Memoria.TamanioPagina
 }
@@ -29,8 +93,23 @@ func CalcularEntradaNivel(nroPagina, nivel, cantEntradas, niveles int) int {
 	return int(math.Floor(float64(nroPagina)/float64(divisor))) % cantEntradas
 }
 
-func ObtenerMarcoMultinivel(pid int, direccionLogica int, niveles int, entradasPorTabla int) (int, error) {
+// Con escritura Memoria le da al proceso su propio marco si la página era copy-on-write
+func ObtenerMarcoMultinivel(pid int, direccionLogica int, niveles int, entradasPorTabla int, escritura bool) (int, error) {
+	marco, _, err := obtenerMarcoMultinivel(pid, direccionLogica, niveles, entradasPorTabla, escritura)
+	return marco, err
+}
+
+// Además del marco devuelve si la página está usada a medias por el proceso
+func obtenerMarcoMultinivel(pid int, direccionLogica int, niveles int, entradasPorTabla int, escritura bool) (int, bool, error) {
 	nroPagina := ObtenerNumeroDePagina(direccionLogica)
+
+	// Las entradas de cada nivel se calculan módulo la cantidad de entradas: una página más allá
+	// de lo que direcciona la tabla caería sobre otra
+	capacidad := int(math.Pow(float64(entradasPorTabla), float64(niveles)))
+	if direccionLogica < 0 || nroPagina >= capacidad {
+		return -1, false, &FallaDeSegmentacion{Direccion: direccionLogica, Motivo: fmt.Sprintf("fuera de las %d páginas que direcciona la tabla", capacidad)}
+	}
+
 	valores := []string{
 		strconv.Itoa(pid),
 	}
@@ -49,37 +128,62 @@ func ObtenerMarcoMultinivel(pid int, direccionLogica int, niveles int, entradasP
 
 	//clientUtils.Logger.Debug("Paquete a enviar en accederMarcoUsuario", "paquete", paquete)
 
+	endpoint := "accederMarcoUsuario"
+	if escritura {
+		endpoint = "accederMarcoEscritura"
+	}
 	resBytes := clientUtils.EnviarPaqueteConRespuestaBody(
 		globalsCpu.CpuConfig.IpMemory,
 		globalsCpu.CpuConfig.PortMemory,
-		"accederMarcoUsuario",
+		endpoint,
 		paquete,
 	)
 
 	if resBytes == nil {
 		clientUtils.Logger.Error("Error: no se recibió respuesta de Memoria (accederMarcoUsuario)")
-		return -1, fmt.Errorf("no se recibió respuesta de Memoria")
+		return -1, false, fmt.Errorf("no se recibió respuesta de Memoria")
 	}
 
 	respuesta := string(resBytes)
+	if strings.TrimSpace(respuesta) == PAGE_FAULT {
+		return -1, false, &FallaDePagina{Pagina: nroPagina}
+	}
+	if err := FallaEnRespuesta(resBytes, direccionLogica); err != nil {
+		return -1, false, err
+	}
+	respuesta, compartida := strings.CutSuffix(strings.TrimSpace(respuesta), " "+COMPARTIDA)
+	if compartida {
+		registrarCompartida(pid, nroPagina)
+	}
+	respuesta, parcial := strings.CutSuffix(respuesta, " "+PARCIAL)
 	marco, err := strconv.Atoi(respuesta)
 
 	if err != nil {
 		clientUtils.Logger.Error("Error al convertir marco de memoria", "respuesta", respuesta, "error", err)
-		return -1, fmt.Errorf("error al convertir marco: %w", err)
+		return -1, false, fmt.Errorf("error al convertir marco: %w", err)
 	}
 
-	return marco, nil
+	return marco, parcial, nil
 
 }
 
 // MMU: Traduce dirección lógica a marco físico, usando TLB + Memoria
 func ObtenerMarco(pid int, direccionLogica int) (int, error) {
+	return obtenerMarco(pid, direccionLogica, false)
+}
+
+// Traducción para escribir en la página. Toda escritura a Memoria tiene que pasar por acá para
+// que una página compartida por un FORK se copie antes de modificarla
+func ObtenerMarcoParaEscritura(pid int, direccionLogica int) (int, error) {
+	return obtenerMarco(pid, direccionLogica, true)
+}
+
+func obtenerMarco(pid int, direccionLogica int, escritura bool) (int, error) {
 	globalsCpu.TlbMutex.Lock()
 	defer globalsCpu.TlbMutex.Unlock()
 	pagina := ObtenerNumeroDePagina(direccionLogica)
 
-	marco, encuentraMarco := tlbUtils.ConsultarMarco(pagina) // Actualiza el último uso
+	marco, encuentraMarco := tlbUtils.ConsultarMarco(pid, pagina, escritura) // Actualiza el último uso
 
 	if encuentraMarco {
 		clientUtils.Logger.Info(fmt.Sprintf("PID: %d - TLB HIT - Pagina: %d", pid, pagina))
@@ -90,13 +194,13 @@ func ObtenerMarco(pid int, direccionLogica int) (int, error) {
 		clientUtils.Logger.Info(fmt.Sprintf("PID: %d - TLB MISS - Pagina: %d", pid, pagina))
 	}
 
-	marco, err := ObtenerMarcoMultinivel(pid, direccionLogica, globalsCpu.Memoria.NivelesPaginacion, globalsCpu.Memoria.CantidadEntradas)
+	marco, parcial, err := obtenerMarcoMultinivel(pid, direccionLogica, globalsCpu.Memoria.NivelesPaginacion, globalsCpu.Memoria.CantidadEntradas, escritura)
 	if err != nil {
 		return -1, err
 	}
 
-	if globalsCpu.CpuConfig.TlbEntries != 0 {
-		tlbUtils.AgregarATLB(pid, pagina, marco)
+	if globalsCpu.CpuConfig.TlbEntries != 0 && !parcial {
+		tlbUtils.AgregarATLB(pid, pagina, marco, escritura)
 	}
 
 	return marco, nil
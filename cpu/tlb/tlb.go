@@ -1,19 +1,137 @@
 package tlb
 
 import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"sync"
 	"time"
 
 	globalsCpu "github.com/sisoputnfrba/tp-golang/cpu/globalsCpu"
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
 )
 
-func AgregarATLB(pid int, pagina int, marco int) {
+// Política de reemplazo de la TLB: con la TLB llena elige el índice de la entrada a reemplazar.
+// Los reemplazos son en el lugar, solo una invalidación de Memoria saca entradas del medio
+type AlgoritmoReemplazo interface {
+	elegirVictima(entradas []globalsCpu.EntradaTLB) int
+}
+
+type FIFOReemplazo struct {
+}
+
+func (f FIFOReemplazo) elegirVictima(entradas []globalsCpu.EntradaTLB) int {
+	return BuscarEntradaMasVieja()
+}
+
+type LRUReemplazo struct {
+}
+
+func (l LRUReemplazo) elegirVictima(entradas []globalsCpu.EntradaTLB) int {
+	return BuscarEntradaMenosUsada()
+}
+
+// Segunda oportunidad: el puntero limpia el bit de uso de las entradas usadas hasta encontrar una sin usar
+type ClockReemplazo struct {
+	puntero int
+}
+
+func (c *ClockReemplazo) elegirVictima(entradas []globalsCpu.EntradaTLB) int {
+	for {
+		c.puntero %= len(entradas)
+		actual := &entradas[c.puntero]
+		c.puntero++
+		if !actual.Uso {
+			return c.puntero - 1
+		}
+		actual.Uso = false
+	}
+}
+
+// La entrada con menos accesos desde que se cargó. Entre las empatadas, la más vieja
+type LFUReemplazo struct {
+}
+
+func (l LFUReemplazo) elegirVictima(entradas []globalsCpu.EntradaTLB) int {
+	victima := 0
+	for i := 1; i < len(entradas); i++ {
+		menosAccesos := entradas[i].Accesos < entradas[victima].Accesos
+		empate := entradas[i].Accesos == entradas[victima].Accesos
+		if menosAccesos || (empate && entradas[i].InstanteCargado.Before(entradas[victima].InstanteCargado)) {
+			victima = i
+		}
+	}
+	return victima
+}
+
+// Víctima al azar con una semilla fija, así dos corridas del mismo script reemplazan igual
+type RandomReemplazo struct {
+	generador *rand.Rand
+}
+
+func (r RandomReemplazo) elegirVictima(entradas []globalsCpu.EntradaTLB) int {
+	return r.generador.Intn(len(entradas))
+}
+
+var algoritmo AlgoritmoReemplazo = FIFOReemplazo{}
+
+// Contadores de la TLB, protegidos por TlbMutex igual que las entradas
+var hits, misses, reemplazos, invalidadas int
+
+// Invalidación pedida por Memoria: las páginas del proceso, o todas si no hay ninguna
+type invalidacion struct {
+	pid     int
+	paginas []int
+}
+
+// Memoria avisa mientras la MMU puede tener tomado TlbMutex esperando una respuesta suya, así que los
+// avisos se encolan aparte y se aplican antes de la próxima consulta o carga
+var (
+	pendientes      []invalidacion
+	mutexPendientes sync.Mutex
+)
+
+// Elige el algoritmo de reemplazo según tlb_replacement
+func IniciarTLB() {
+	config := globalsCpu.CpuConfig
+	switch config.TlbReplacement {
+	case "FIFO":
+		algoritmo = FIFOReemplazo{}
+	case "LRU":
+		algoritmo = LRUReemplazo{}
+	case "CLOCK":
+		algoritmo = &ClockReemplazo{}
+	case "LFU":
+		algoritmo = LFUReemplazo{}
+	case "RANDOM":
+		algoritmo = RandomReemplazo{generador: rand.New(rand.NewSource(config.TlbRandomSeed))}
+	default:
+		if config.TlbEntries != 0 {
+			panic(fmt.Sprintf("tlb_replacement desconocido: %s", config.TlbReplacement))
+		}
+	}
+}
+
+func AgregarATLB(pid int, pagina int, marco int, escritura bool) {
+	aplicarInvalidaciones()
 	entrada := globalsCpu.EntradaTLB{
 		Pid:             pid,
 		Pagina:          pagina,
 		Marco:           marco,
+		Escritura:       escritura,
 		UltimoUso:       time.Now(),
 		InstanteCargado: time.Now(),
+		Uso:             true,
+	}
+
+	// Una traducción para escribir reemplaza a la de lectura de la misma página (el marco pudo cambiar)
+	for i := range globalsCpu.Tlb {
+		if globalsCpu.Tlb[i].Pid == pid && globalsCpu.Tlb[i].Pagina == pagina {
+			entrada.Accesos = globalsCpu.Tlb[i].Accesos
+			entrada.InstanteCargado = globalsCpu.Tlb[i].InstanteCargado
+			globalsCpu.Tlb[i] = entrada
+			return
+		}
 	}
 
 	if len(globalsCpu.Tlb) < globalsCpu.CpuConfig.TlbEntries {
@@ -22,19 +140,10 @@ func AgregarATLB(pid int, pagina int, marco int) {
 		return
 	}
 
-	// Reemplazo por FIFO o LRU
-	switch globalsCpu.CpuConfig.TlbReplacement {
-	case "FIFO":
-		indice := BuscarEntradaMasVieja()
-		//clientUtils.Logger.Debug("El indice es ", "indice", indice)
-		//clientUtils.Logger.Debug("Se reemplazo la tlb: ", "TLB: ", globalsCpu.Tlb[indice])
-		globalsCpu.Tlb[indice] = entrada
-	case "LRU":
-		indice := BuscarEntradaMenosUsada()
-		//clientUtils.Logger.Debug("Se reemplazo la tlb: ", "TLB: ", globalsCpu.Tlb[indice])
-		globalsCpu.Tlb[indice] = entrada
-	}
-	//clientUtils.Logger.Debug("TLB Replace", "PID", pid, "Página", pagina, "Marco", marco)
+	indice := algoritmo.elegirVictima(globalsCpu.Tlb)
+	clientUtils.Logger.Debug("TLB Replace", "PID", pid, "Víctima", globalsCpu.Tlb[indice].Pagina, "Página", pagina, "Marco", marco)
+	globalsCpu.Tlb[indice] = entrada
+	reemplazos++
 }
 
 func BuscarEntradaMasVieja() int {
@@ -61,23 +170,96 @@ func BuscarEntradaMenosUsada() int {
 	return menosUsada
 }
 
-func ConsultarMarco(pagina int) (int, bool) {
+// Para escribir solo sirve una entrada que se cargó para escribir
+func ConsultarMarco(pid int, pagina int, escritura bool) (int, bool) {
 	if globalsCpu.CpuConfig.TlbEntries == 0 {
 		return -1, false
 	}
+	aplicarInvalidaciones()
 
 	for i, entrada := range globalsCpu.Tlb {
-		if entrada.Pagina == pagina {
+		if entrada.Pid == pid && entrada.Pagina == pagina && (entrada.Escritura || !escritura) {
 			globalsCpu.Tlb[i].UltimoUso = time.Now()
+			globalsCpu.Tlb[i].Uso = true
+			globalsCpu.Tlb[i].Accesos++
+			hits++
 			return entrada.Marco, true
 		}
 	}
+	misses++
 	return -1, false
 }
 
+// Encola la invalidación de las páginas del proceso, o de todas sus entradas si no se pasa ninguna
+func Invalidar(pid int, paginas []int) {
+	mutexPendientes.Lock()
+	defer mutexPendientes.Unlock()
+	pendientes = append(pendientes, invalidacion{pid, paginas})
+}
+
+// Se llama con TlbMutex tomado
+func aplicarInvalidaciones() {
+	mutexPendientes.Lock()
+	aplicar := pendientes
+	pendientes = nil
+	mutexPendientes.Unlock()
+
+	for _, pedido := range aplicar {
+		quedan := globalsCpu.Tlb[:0]
+		for _, entrada := range globalsCpu.Tlb {
+			if entrada.Pid == pedido.pid && (len(pedido.paginas) == 0 || slices.Contains(pedido.paginas, entrada.Pagina)) {
+				clientUtils.Logger.Debug("TLB Invalidate", "PID", entrada.Pid, "Página", entrada.Pagina)
+				invalidadas++
+				continue
+			}
+			quedan = append(quedan, entrada)
+		}
+		globalsCpu.Tlb = quedan
+	}
+}
+
+// Copia de las entradas vigentes, sin las que Memoria ya avisó que dejaron de valer
+func Entradas() []globalsCpu.EntradaTLB {
+	globalsCpu.TlbMutex.Lock()
+	defer globalsCpu.TlbMutex.Unlock()
+	aplicarInvalidaciones()
+	return append([]globalsCpu.EntradaTLB{}, globalsCpu.Tlb...)
+}
+
 func LimpiarTLB() {
 	globalsCpu.TlbMutex.Lock()
 	defer globalsCpu.TlbMutex.Unlock()
 	globalsCpu.Tlb = []globalsCpu.EntradaTLB{}
 	clientUtils.Logger.Info("TLB Cleared")
 }
+
+// Contadores de la TLB desde que arrancó la CPU, para comparar algoritmos sobre un mismo script
+type MetricasTLB struct {
+	Algoritmo   string  `json:"algoritmo"`
+	Entradas    int     `json:"entradas"`
+	Asid        bool    `json:"asid"`
+	Hits        int     `json:"hits"`
+	Misses      int     `json:"misses"`
+	Reemplazos  int     `json:"reemplazos"`
+	Invalidadas int     `json:"invalidadas"` // entradas descartadas por avisos de Memoria (solo con tlb_asid)
+	TasaHits    float64 `json:"tasa_hits"`
+}
+
+func Metricas() MetricasTLB {
+	globalsCpu.TlbMutex.Lock()
+	defer globalsCpu.TlbMutex.Unlock()
+	aplicarInvalidaciones()
+	metricas := MetricasTLB{
+		Algoritmo:   globalsCpu.CpuConfig.TlbReplacement,
+		Entradas:    globalsCpu.CpuConfig.TlbEntries,
+		Asid:        globalsCpu.CpuConfig.TlbAsid,
+		Hits:        hits,
+		Misses:      misses,
+		Reemplazos:  reemplazos,
+		Invalidadas: invalidadas,
+	}
+	if consultas := hits + misses; consultas > 0 {
+		metricas.TasaHits = float64(hits) / float64(consultas)
+	}
+	return metricas
+}
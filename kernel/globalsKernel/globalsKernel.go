@@ -1,16 +1,39 @@
 package globalskernel
 
 type Config struct {
-	IpMemory              string  `json:"ip_memory"`
-	PortMemory            int     `json:"port_memory"`
-	IpKernel              string  `json:"ip_kernel"`
-	PortKernel            int     `json:"port_kernel"`
-	SchedulerAlgorithm    string  `json:"scheduler_algorithm"`
-	ReadyIngressAlgorithm string  `json:"ready_ingress_algorithm"`
-	Alpha                 float64 `json:"alpha"`
-	InitialEstimate       int     `json:"initial_estimate"`
-	SuspensionTime        int     `json:"suspension_time"`
-	LogLevel              string  `json:"log_level"`
+	IpMemory               string      `json:"ip_memory"`
+	PortMemory             int         `json:"port_memory"`
+	IpKernel               string      `json:"ip_kernel"`
+	PortKernel             int         `json:"port_kernel"`
+	SchedulerAlgorithm     string      `json:"scheduler_algorithm"`
+	ReadyIngressAlgorithm  string      `json:"ready_ingress_algorithm"`
+	Alpha                  float64     `json:"alpha"`
+	InitialEstimate        int         `json:"initial_estimate"`
+	SuspensionTime         int         `json:"suspension_time"`
+	Quantum                int         `json:"quantum"`
+	MlfqNiveles            []NivelMLFQ `json:"mlfq_niveles"`
+	MlfqAging              int         `json:"mlfq_aging"`
+	PriorityAging          int         `json:"priority_aging"`
+	GradoMultiprogramacion int         `json:"grado_multiprogramacion"` // 0 = sin límite
+	PoliticaHuerfanos      string      `json:"politica_huerfanos"`      // CASCADA o REPARENTAR
+	Recursos               []Recurso   `json:"recursos"`
+	DeteccionDeadlock      string      `json:"deteccion_deadlock"`  // NINGUNA, PERIODICA o AL_BLOQUEAR
+	IntervaloDeteccion     int         `json:"intervalo_deteccion"` // ms, para la detección periódica
+	VictimaDeadlock        string      `json:"victima_deadlock"`    // vacío (solo loguea), MAS_JOVEN, MENOR_TAMANIO o MENOR_PRIORIDAD
+	LogLevel               string      `json:"log_level"`
+}
+
+// Cada nivel de la MLFQ tiene su propio quantum (0 = sin quantum) y algoritmo (FIFO, RR o SJF)
+type NivelMLFQ struct {
+	Quantum   int    `json:"quantum"`
+	Algoritmo string `json:"algoritmo"`
+}
+
+// Recurso del Kernel para sincronizar procesos. Un MUTEX tiene una sola instancia y dueño
+type Recurso struct {
+	Nombre     string `json:"nombre"`
+	Instancias int    `json:"instancias"`
+	Tipo       string `json:"tipo"` // SEMAFORO (por defecto) o MUTEX
 }
 
 var KernelConfig *Config
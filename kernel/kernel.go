@@ -20,6 +20,8 @@ func main() {
 
 	kernelUtils.Plp = kernelUtils.InciarPlp()
 	kernelUtils.Pmp = kernelUtils.IniciarPmp()
+	kernelUtils.IniciarRecursos()
+	kernelUtils.IniciarDeteccionDeadlock()
 
 	args := os.Args
 	filePath := args[1]
@@ -27,6 +29,14 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	// La prioridad del proceso inicial es opcional
+	prioridad := 0
+	if len(args) > 3 {
+		prioridad, err = strconv.Atoi(args[3])
+		if err != nil {
+			panic(err)
+		}
+	}
 
 	// Crea el multiplexer HTTP para registrar handlers
 	mux := http.NewServeMux()
@@ -38,6 +48,9 @@ func main() {
 	// Las CPUs envían resultados o finalización a /resultadoProcesos
 	mux.HandleFunc("/resultadoProcesos", kernelUtils.ResultadoProcesos)
 
+	// Las CPUs avisan a /interrupcionDescartada cuando tiran una interrupción de un proceso que ya no ejecutan
+	mux.HandleFunc("/interrupcionDescartada", kernelUtils.InterrupcionDescartada)
+
 	// IOs envían handshake a /ios
 	mux.HandleFunc("/ios", kernelUtils.RegistrarIo)
 
@@ -46,12 +59,21 @@ func main() {
 
 	mux.HandleFunc("/desconexionIos", kernelUtils.DesconexionIos)
 
+	// Endpoints de administración para consultar y controlar los procesos
+	mux.HandleFunc("/admin/procesos", kernelUtils.ListarProcesos)
+	mux.HandleFunc("/admin/iniciarProceso", kernelUtils.CrearProceso)
+	mux.HandleFunc("/admin/finalizarProceso", kernelUtils.EliminarProceso)
+	mux.HandleFunc("/admin/detenerPlanificacion", kernelUtils.DetenerPlanificacion)
+	mux.HandleFunc("/admin/iniciarPlanificacion", kernelUtils.ReanudarPlanificacion)
+	mux.HandleFunc("/admin/grafoEspera", kernelUtils.GrafoDeEspera)
+	mux.HandleFunc("/admin/multiprogramacion", kernelUtils.CambiarMultiprogramacion)
+
 	// Levanta el servidor en el puerto definido en el archivo de configuración
 	direccion := fmt.Sprintf("%s:%d", globalsKernel.KernelConfig.IpKernel, globalsKernel.KernelConfig.PortKernel)
 	fmt.Printf("[Kernel] Servidor HTTP escuchando en puerto %d...\n", globalsKernel.KernelConfig.PortKernel)
 
-	go kernelUtils.EsperarEnter()
-	go kernelUtils.IniciarKernel(filePath, uint(tamProc))
+	go kernelUtils.IniciarConsola()
+	go kernelUtils.IniciarKernel(filePath, uint(tamProc), prioridad)
 
 	err = http.ListenAndServe(direccion, mux)
 	if err != nil {
@@ -0,0 +1,181 @@
+package kernelUtils
+
+import (
+	"reflect"
+	"testing"
+
+	globalskernel "github.com/sisoputnfrba/tp-golang/kernel/globalsKernel"
+)
+
+func TestProcesosEnDeadlock(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		estado     estadoDeEspera
+		enDeadlock []uint
+	}{
+		{
+			nombre: "dos mutex cruzados",
+			estado: estadoDeEspera{
+				disponibles: map[string]int{"A": 0, "B": 0},
+				asignados:   map[uint]map[string]int{1: {"A": 1}, 2: {"B": 1}},
+				pedidos:     map[uint][]string{1: {"B"}, 2: {"A"}},
+			},
+			enDeadlock: []uint{1, 2},
+		},
+		{
+			// 1 espera una instancia de S que tiene 3; 3 no espera nada, así que al terminar destraba a 1 y luego a 2
+			nombre: "ciclo por un semáforo de varias instancias",
+			estado: estadoDeEspera{
+				disponibles: map[string]int{"S": 0, "M": 0},
+				asignados:   map[uint]map[string]int{1: {"S": 1, "M": 1}, 3: {"S": 1}},
+				pedidos:     map[uint][]string{1: {"S"}, 2: {"M"}},
+			},
+			enDeadlock: []uint{},
+		},
+		{
+			nombre: "espera de IO a un proceso bloqueado por un recurso",
+			estado: estadoDeEspera{
+				disponibles: map[string]int{"A": 0},
+				asignados:   map[uint]map[string]int{1: {"A": 1}},
+				pedidos:     map[uint][]string{1: {"A"}},
+				depende:     map[uint][]uint{2: {1}},
+			},
+			enDeadlock: []uint{1, 2},
+		},
+		{
+			nombre: "cola de IO con un usuario que puede terminar",
+			estado: estadoDeEspera{
+				disponibles: map[string]int{},
+				asignados:   map[uint]map[string]int{},
+				pedidos:     map[uint][]string{},
+				depende:     map[uint][]uint{2: {1}, 3: {2}},
+			},
+			enDeadlock: []uint{},
+		},
+		{
+			nombre: "WAIT_PID mutuo",
+			estado: estadoDeEspera{
+				depende: map[uint][]uint{1: {2}, 2: {1}},
+			},
+			enDeadlock: []uint{1, 2},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			obtenido := caso.estado.procesosEnDeadlock()
+			esperado := make(map[uint]bool)
+			for _, pid := range caso.enDeadlock {
+				esperado[pid] = true
+			}
+			if !reflect.DeepEqual(obtenido, esperado) {
+				t.Errorf("procesosEnDeadlock() = %v, se esperaba %v", obtenido, esperado)
+			}
+		})
+	}
+}
+
+func TestBuscarCiclos(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		aristas []AristaEspera
+		ciclos  [][]uint
+	}{
+		{
+			nombre:  "sin aristas",
+			aristas: nil,
+			ciclos:  [][]uint{},
+		},
+		{
+			nombre:  "cadena sin ciclo",
+			aristas: []AristaEspera{{Desde: 1, Hasta: 2}, {Desde: 2, Hasta: 3}},
+			ciclos:  [][]uint{},
+		},
+		{
+			nombre:  "ciclo de tres",
+			aristas: []AristaEspera{{Desde: 1, Hasta: 2}, {Desde: 2, Hasta: 3}, {Desde: 3, Hasta: 1}},
+			ciclos:  [][]uint{{1, 2, 3}},
+		},
+		{
+			nombre:  "ciclo que no empieza en el primer nodo",
+			aristas: []AristaEspera{{Desde: 1, Hasta: 2}, {Desde: 2, Hasta: 3}, {Desde: 3, Hasta: 2}},
+			ciclos:  [][]uint{{2, 3}},
+		},
+		{
+			nombre:  "dos componentes con ciclo",
+			aristas: []AristaEspera{{Desde: 1, Hasta: 2}, {Desde: 2, Hasta: 1}, {Desde: 5, Hasta: 6}, {Desde: 6, Hasta: 5}},
+			ciclos:  [][]uint{{1, 2}, {5, 6}},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			if obtenido := buscarCiclos(caso.aristas); !reflect.DeepEqual(obtenido, caso.ciclos) {
+				t.Errorf("buscarCiclos() = %v, se esperaba %v", obtenido, caso.ciclos)
+			}
+		})
+	}
+}
+
+// Un semáforo de dos instancias con un dueño que no espera nada no deja ciclos en el grafo,
+// aunque haya aristas que formen uno
+func TestConstruirGrafoEsperaConVariasInstancias(t *testing.T) {
+	recursosOriginales := recursos
+	defer func() { recursos = recursosOriginales }()
+
+	recursos = map[string]*Recurso{
+		"S": {Nombre: "S", Tipo: "SEMAFORO", instancias: -1, asignados: map[uint]int{1: 1, 3: 1}, bloqueados: []uint{2}},
+		"M": {Nombre: "M", Tipo: "MUTEX", instancias: -1, asignados: map[uint]int{2: 1}, bloqueados: []uint{1}},
+	}
+	grafo := construirGrafoEspera()
+	if len(grafo.Aristas) != 3 {
+		t.Errorf("se esperaban 3 aristas, hay %v", grafo.Aristas)
+	}
+	if len(grafo.Ciclos) != 0 {
+		t.Errorf("no se esperaban ciclos, hay %v", grafo.Ciclos)
+	}
+
+	// Sin el dueño libre la espera es circular de verdad
+	recursos["S"].asignados = map[uint]int{1: 2}
+	grafo = construirGrafoEspera()
+	if esperado := [][]uint{{1, 2}}; !reflect.DeepEqual(grafo.Ciclos, esperado) {
+		t.Errorf("ciclos = %v, se esperaba %v", grafo.Ciclos, esperado)
+	}
+}
+
+func TestElegirVictima(t *testing.T) {
+	configOriginal := globalskernel.KernelConfig
+	tablaOriginal := tablaProcesos
+	defer func() {
+		globalskernel.KernelConfig = configOriginal
+		tablaProcesos = tablaOriginal
+	}()
+
+	tablaProcesos = map[uint]*PCB{
+		1: {PID: 1, ProcessSize: 64, Prioridad: 2},
+		2: {PID: 2, ProcessSize: 256, Prioridad: 5},
+		3: {PID: 3, ProcessSize: 32, Prioridad: 1},
+	}
+	ciclo := []uint{1, 2, 3}
+
+	casos := []struct {
+		politica string
+		victima  uint
+		elige    bool
+	}{
+		{politica: "MAS_JOVEN", victima: 3, elige: true},
+		{politica: "MENOR_TAMANIO", victima: 3, elige: true},
+		{politica: "MENOR_PRIORIDAD", victima: 2, elige: true},
+		{politica: "", elige: false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.politica, func(t *testing.T) {
+			globalskernel.KernelConfig = &globalskernel.Config{VictimaDeadlock: caso.politica}
+			victima, elige := elegirVictima(ciclo)
+			if elige != caso.elige || (elige && victima != caso.victima) {
+				t.Errorf("elegirVictima() = (%d, %v), se esperaba (%d, %v)", victima, elige, caso.victima, caso.elige)
+			}
+		})
+	}
+}
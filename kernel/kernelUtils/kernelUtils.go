@@ -4,16 +4,19 @@ import (
 	"bufio"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	globalskernel "github.com/sisoputnfrba/tp-golang/kernel/globalsKernel"
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
+	registros "github.com/sisoputnfrba/tp-golang/utils/registros"
 	serverUtils "github.com/sisoputnfrba/tp-golang/utils/server"
 )
 
@@ -30,8 +33,6 @@ var muProximoPID sync.Mutex
 var Plp PlanificadorLargoPlazo
 var Pmp PlanificadorMedianoPlazo
 
-var iniciarLargoPlazo = make(chan struct{})
-
 func IniciarConfiguracion(filePath string) *globalskernel.Config {
 	config := &globalskernel.Config{} // Aca creamos el contenedor donde irá el JSON
 
@@ -60,12 +61,24 @@ func InciarPcp() PlanificadorCortoPlazo {
 		estrategia = SJFScheduler{}
 	} else if algoritmo == "SRT" {
 		estrategia = SRTScheduler{}
+	} else if algoritmo == "RR" {
+		estrategia = RRScheduler{}
+	} else if algoritmo == "VRR" {
+		estrategia = VRRScheduler{}
+	} else if algoritmo == "MLFQ" {
+		if len(globalskernel.KernelConfig.MlfqNiveles) == 0 {
+			panic("MLFQ requiere al menos un nivel en mlfq_niveles")
+		}
+		estrategia = MLFQScheduler{}
+	} else if algoritmo == "PRIORIDADES" {
+		estrategia = PrioridadesScheduler{}
 	}
 
 	return PlanificadorCortoPlazo{schedulerEstrategy: estrategia}
 }
 
 func InciarPlp() PlanificadorLargoPlazo {
+	multiprogramacion.limite = globalskernel.KernelConfig.GradoMultiprogramacion
 	var estrategia NewAlgorithmEstrategy
 	algoritmo := globalskernel.KernelConfig.ReadyIngressAlgorithm
 	if algoritmo == "FIFO" {
@@ -90,39 +103,75 @@ func IniciarPmp() PlanificadorMedianoPlazo {
 
 // Estructura para representar CPUs e IOs conectados al Kernel
 type Cpu struct {
-	Identificador            string `json:"identificador"`
-	Ip                       string `json:"ip"`
-	Puerto                   int    `json:"puerto"`
-	PIDenEjecucion           uint
-	sem_interrupcionAtendida chan struct{}
+	Identificador            string        `json:"identificador"`
+	Ip                       string        `json:"ip"`
+	Puerto                   int           `json:"puerto"`
+	PIDenEjecucion           atomic.Uint64 // lo escriben el despacho y las devoluciones, lo lee el timer del quantum
+	sem_interrupcionAtendida chan bool     // true: la CPU queda para el que pidió el desalojo, false: el desalojo no se hizo
 }
 
-func (cpu *Cpu) enviarProceso(PID uint, PC uint) {
-	valores := []string{strconv.Itoa(int(PID)), strconv.Itoa(int(PC))}
+func (cpu *Cpu) enviarProceso(PID uint, PC uint, contexto registros.Registros) {
+	valores := []string{strconv.Itoa(int(PID)), strconv.Itoa(int(PC)), contexto.Serializar()}
 	paquete := clientUtils.Paquete{Valores: valores}
-	cpu.PIDenEjecucion = PID
+	cpu.PIDenEjecucion.Store(uint64(PID))
 	//Mandamos el PID y PC al endpoint de CPU
 	endpoint := "recibirProceso"
 
 	clientUtils.EnviarPaquete(cpu.Ip, cpu.Puerto, endpoint, paquete)
 }
 
-func (cpu *Cpu) enviarInterrupcion(motivo string) {
-	valores := []string{motivo}
+func (cpu *Cpu) enviarInterrupcion(motivo string, PID uint) {
+	// Se manda el PID para que la CPU descarte interrupciones de un proceso que ya no ejecuta
+	valores := []string{motivo, strconv.Itoa(int(PID))}
 	paquete := clientUtils.Paquete{Valores: valores}
 	endpoint := "recibirInterrupcion"
 
 	clientUtils.EnviarPaquete(cpu.Ip, cpu.Puerto, endpoint, paquete)
 }
 
-func (cpu *Cpu) enviarFinInitProc() {
-	valores := []string{}
+// Avisa a la CPU que terminó de atenderse una syscall sincrónica para que el proceso siga
+// ejecutando o la suelte. El endpoint conserva el nombre de cuando solo lo usaba INIT_PROC
+func (cpu *Cpu) enviarFinSyscall(valores ...string) {
 	paquete := clientUtils.Paquete{Valores: valores}
 	endpoint := "finSyscallInitProc"
 
 	clientUtils.EnviarPaquete(cpu.Ip, cpu.Puerto, endpoint, paquete)
 }
 
+// El proceso sigue en la CPU después de atender su syscall sincrónica
+func (cpu *Cpu) continuarTrasSyscall(proceso *PCB, valores ...string) {
+	proceso.timeInCurrentState = time.Now()
+	Plp.pcp.execState.Agregar(proceso)
+	go cpu.enviarFinSyscall(append([]string{SYSCALL_CONTINUAR}, valores...)...)
+}
+
+// El proceso deja la CPU durante una syscall sincrónica (se bloqueó o terminó).
+// La CPU se libera recién cuando sabe que tiene que soltarlo
+func (cpu *Cpu) liberarTrasSyscall(proceso *PCB) {
+	proceso.detenerQuantum()
+	desalojado := proceso.estaSiendoDesalojado.Swap(false)
+	go func() {
+		cpu.enviarFinSyscall(SYSCALL_BLOQUEADO)
+		cpu.soltar(desalojado)
+	}()
+}
+
+// El proceso dejó la CPU por su cuenta. Si mientras tanto se pidió desalojarlo, la interrupción ya no
+// lo va a encontrar y la CPU pasa directo al proceso que pidió el desalojo
+func (cpu *Cpu) liberar(proceso *PCB) {
+	cpu.soltar(proceso.estaSiendoDesalojado.Swap(false))
+}
+
+func (cpu *Cpu) soltar(desalojado bool) {
+	if desalojado {
+		// la CPU ya está reservada para el proceso que pidió el desalojo
+		cpu.sem_interrupcionAtendida <- true
+	} else {
+		cpusLibres.Agregar(cpusOcupadas.SacarPorID(cpu.Identificador))
+		sem_cpusLibres <- 1
+	}
+}
+
 type CpuList struct {
 	cpus []*Cpu
 	mu   sync.Mutex
@@ -194,7 +243,7 @@ func (cl *CpuList) BuscarPorPIDEnEjecucion(pid uint) (*Cpu, bool) {
 	defer cl.mu.Unlock()
 
 	for _, cpu := range cl.cpus {
-		if cpu.PIDenEjecucion == pid {
+		if cpu.PIDenEjecucion.Load() == uint64(pid) {
 			return cpu, true
 		}
 	}
@@ -253,6 +302,34 @@ func (gi *GrupoIo) SacarProximoPedido() (PedidoIo, bool) {
 	return prox, true
 }
 
+// Saca el pedido pendiente de un proceso, por ejemplo cuando se lo finaliza mientras espera
+func (gi *GrupoIo) SacarPedidoPorPID(pid uint) bool {
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+	for i, pedido := range gi.procesosEsperando {
+		if pedido.PID == pid {
+			gi.procesosEsperando = append(gi.procesosEsperando[:i], gi.procesosEsperando[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// PIDs que esperan en la cola y PIDs que están usando alguna instancia
+func (gi *GrupoIo) EstadoDeUso() (esperando []uint, usando []uint) {
+	gi.mu.Lock()
+	defer gi.mu.Unlock()
+	for _, pedido := range gi.procesosEsperando {
+		esperando = append(esperando, pedido.PID)
+	}
+	for _, io := range gi.Ios {
+		if pid := io.ObtenerPIDEnEjecucion(); pid >= 0 {
+			usando = append(usando, uint(pid))
+		}
+	}
+	return esperando, usando
+}
+
 func (gi *GrupoIo) AgregarPedido(p PedidoIo) {
 	gi.mu.Lock()
 	defer gi.mu.Unlock()
@@ -371,6 +448,25 @@ func (im *IoMap) AgregarGrupoIo(grupo *GrupoIo) {
 	}
 }
 
+func (im *IoMap) Grupos() []*GrupoIo {
+	im.mu.Lock()
+	defer im.mu.Unlock()
+	grupos := make([]*GrupoIo, 0, len(im.ios))
+	for _, grupo := range im.ios {
+		grupos = append(grupos, grupo)
+	}
+	return grupos
+}
+
+// Saca los pedidos pendientes de un proceso de todos los grupos de IO
+func (im *IoMap) CancelarPedidos(pid uint) {
+	for _, grupo := range im.Grupos() {
+		if grupo.SacarPedidoPorPID(pid) {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Se cancela su pedido pendiente a la IO %s", pid, grupo.Nombre))
+		}
+	}
+}
+
 func (im *IoMap) BuscarIoPorGrupoYPID(nombre string, pid int) (*Io, bool) {
 	im.mu.Lock()
 	grupo, ok := im.ios[nombre]
@@ -408,6 +504,7 @@ type MetricasDeEstado struct {
 	readyCount       uint
 	execCount        uint
 	blockedCount     uint
+	recursoCount     uint // bloqueos esperando un recurso, ya incluidos en blockedCount
 	suspReadyCount   uint
 	suspBlockedCount uint
 	exitCount        uint
@@ -418,22 +515,65 @@ type MetricasDeTiempo struct {
 	readyTime       float64
 	execTime        float64
 	blockedTime     float64
+	recursoTime     float64
 	suspReadyTime   float64
 	suspBlockedTime float64
 	exitTime        float64
 }
 
+// Ingresos y tiempo (ms) en cada nivel de la MLFQ, indexados por nivel
+type MetricasDeNivel struct {
+	ingresos []uint
+	tiempos  []float64
+}
+
+func (mn *MetricasDeNivel) registrarIngreso(nivel int) {
+	for len(mn.ingresos) <= nivel {
+		mn.ingresos = append(mn.ingresos, 0)
+		mn.tiempos = append(mn.tiempos, 0)
+	}
+	mn.ingresos[nivel]++
+}
+
+func (mn *MetricasDeNivel) sumarTiempo(nivel int, tiempo float64) {
+	for len(mn.tiempos) <= nivel {
+		mn.ingresos = append(mn.ingresos, 0)
+		mn.tiempos = append(mn.tiempos, 0)
+	}
+	mn.tiempos[nivel] += tiempo
+}
+
 type PCB struct {
 	PID                  uint
 	PC                   uint
+	Registros            registros.Registros
 	ProcessSize          uint
 	FilePath             string
+	Prioridad            int // menor número = mayor prioridad
+	PIDPadre             int // -1 si no fue creado por otro proceso
+	finalizado           bool
+	enMemoria            atomic.Bool // ocupa un lugar del grado de multiprogramación
+	scriptInvalido       bool        // Memoria no pudo ensamblar su pseudocódigo, nunca va a poder iniciar
+	forkeado             bool        // creado por FORK: Memoria ya tiene sus páginas desde antes de admitirlo
+	motivoFinalizacion   string      // vacío si terminó normalmente, si no el error que lo finalizó
+	inicioEsperaRecurso  time.Time
+	prioridadBase        int
 	ME                   MetricasDeEstado
 	MT                   MetricasDeTiempo
 	timeInCurrentState   time.Time
 	estimacion           float64
 	estaSiendoDesalojado atomic.Bool
 	pidioDesalojo        atomic.Bool
+	finalizacionPedida   atomic.Bool
+	inicioRafaga         time.Time
+	quantumAsignado      float64
+	quantumRestante      float64
+	timerQuantum         *time.Timer
+	mutexQuantum         sync.Mutex // protege timerQuantum, que se arma al despachar y se frena al volver
+	nivel                int
+	inicioNivel          time.Time
+	ultimaPromocion      time.Time
+	MN                   MetricasDeNivel
 }
 
 type PCBList struct {
@@ -467,6 +607,148 @@ func (p *PCBList) SacarProximoProceso() (*PCB, bool) {
 	// Si todos pidieron desalojo, no hay ninguno disponible
 	return nil, false
 }
+
+// Saca el primer proceso que volvió de un bloqueo sin consumir todo su quantum (cola auxiliar de VRR)
+func (p *PCBList) SacarProximoConQuantumRestante() (*PCB, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, pcb := range p.elementos {
+		if pcb.quantumRestante > 0 && !pcb.pidioDesalojo.Load() {
+			p.elementos = append(p.elementos[:i], p.elementos[i+1:]...)
+			return pcb, true
+		}
+	}
+	return nil, false
+}
+
+// Saca el próximo proceso del nivel pedido, por orden de llegada o por menor estimación
+func (p *PCBList) SacarProximoDeNivel(nivel int, porEstimacion bool) (*PCB, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	indice := -1
+	for i, pcb := range p.elementos {
+		if pcb.nivel != nivel || pcb.pidioDesalojo.Load() {
+			continue
+		}
+		if indice == -1 {
+			indice = i
+			if !porEstimacion {
+				break
+			}
+		} else if pcb.estimacion < p.elementos[indice].estimacion {
+			indice = i
+		}
+	}
+
+	if indice == -1 {
+		return nil, false
+	}
+	proceso := p.elementos[indice]
+	p.elementos = append(p.elementos[:indice], p.elementos[indice+1:]...)
+	return proceso, true
+}
+
+// Sube un nivel a los procesos que esperan hace más de "espera" ms y devuelve los promovidos
+func (p *PCBList) EnvejecerNiveles(espera float64) []*PCB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var promovidos []*PCB
+	for _, pcb := range p.elementos {
+		if pcb.nivel == 0 {
+			continue
+		}
+		desde := pcb.timeInCurrentState
+		if pcb.ultimaPromocion.After(desde) {
+			desde = pcb.ultimaPromocion
+		}
+		if float64(time.Since(desde).Microseconds())/1000.0 >= espera {
+			pcb.cambiarNivel(pcb.nivel - 1)
+			pcb.ultimaPromocion = time.Now()
+			promovidos = append(promovidos, pcb)
+		}
+	}
+	return promovidos
+}
+
+// Saca el proceso de mayor prioridad (menor número), a igual prioridad el que llegó primero
+func (p *PCBList) SacarProcesoConMayorPrioridad() (*PCB, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	indice := -1
+	for i, pcb := range p.elementos {
+		if pcb.pidioDesalojo.Load() {
+			continue
+		}
+		if indice == -1 || pcb.Prioridad < p.elementos[indice].Prioridad {
+			indice = i
+		}
+	}
+
+	if indice == -1 {
+		return nil, false
+	}
+	proceso := p.elementos[indice]
+	p.elementos = append(p.elementos[:indice], p.elementos[indice+1:]...)
+	return proceso, true
+}
+
+// Busca el proceso de menor prioridad (mayor número) que no esté siendo desalojado
+func (p *PCBList) BuscarProcesoConMenorPrioridad() (*PCB, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var peor *PCB
+	for _, pcb := range p.elementos {
+		if pcb.estaSiendoDesalojado.Load() {
+			continue
+		}
+		if peor == nil || pcb.Prioridad > peor.Prioridad {
+			peor = pcb
+		}
+	}
+	return peor, peor != nil
+}
+
+// Mejora en uno la prioridad de los procesos que esperan hace más de "espera" ms
+func (p *PCBList) EnvejecerPrioridades(espera float64) []*PCB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var promovidos []*PCB
+	for _, pcb := range p.elementos {
+		if pcb.Prioridad == 0 {
+			continue
+		}
+		desde := pcb.timeInCurrentState
+		if pcb.ultimaPromocion.After(desde) {
+			desde = pcb.ultimaPromocion
+		}
+		if float64(time.Since(desde).Microseconds())/1000.0 >= espera {
+			pcb.Prioridad--
+			pcb.ultimaPromocion = time.Now()
+			promovidos = append(promovidos, pcb)
+		}
+	}
+	return promovidos
+}
+
+func (p *PCBList) ContarPorNivel(nivel int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	cantidad := 0
+	for _, pcb := range p.elementos {
+		if pcb.nivel == nivel {
+			cantidad++
+		}
+	}
+	return cantidad
+}
+
 func (p *PCBList) EliminarProcesoPorPID(pid uint) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -512,6 +794,13 @@ func (p *PCBList) Vacia() bool {
 	return len(p.elementos) == 0
 }
 
+// Copia de los procesos de la lista, para consultarla sin mantener el lock
+func (p *PCBList) Listar() []*PCB {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*PCB{}, p.elementos...)
+}
+
 func (p *PCBList) VizualizarProximo() *PCB {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -586,6 +875,33 @@ func (p *PCB) timeInState() float64 {
 	return float64(time.Since(p.timeInCurrentState).Microseconds()) / 1000.0
 }
 
+// Tiempo en milisegundos desde que el proceso fue despachado a la CPU
+func (p *PCB) tiempoEnRafaga() float64 {
+	return float64(time.Since(p.inicioRafaga).Microseconds()) / 1000.0
+}
+
+func (p *PCB) detenerQuantum() {
+	p.mutexQuantum.Lock()
+	defer p.mutexQuantum.Unlock()
+	if p.timerQuantum != nil {
+		p.timerQuantum.Stop()
+		p.timerQuantum = nil
+	}
+}
+
+func (p *PCB) acumularTiempoEnNivel() {
+	if !p.inicioNivel.IsZero() {
+		p.MN.sumarTiempo(p.nivel, float64(time.Since(p.inicioNivel).Microseconds())/1000.0)
+	}
+	p.inicioNivel = time.Now()
+}
+
+func (p *PCB) cambiarNivel(nuevoNivel int) {
+	p.acumularTiempoEnNivel()
+	p.nivel = nuevoNivel
+	p.MN.registrarIngreso(nuevoNivel)
+}
+
 func (p *PCB) calcularProximaEstimacion(rafagaReal float64, motivo string) {
 	if motivo != "DESALOJO" {
 		p.estimacion = globalskernel.KernelConfig.Alpha*rafagaReal + (1-globalskernel.KernelConfig.Alpha)*p.estimacion
@@ -609,16 +925,20 @@ func (f FIFOEstrategy) manejarIngresoDeProceso(nuevoProceso *PCB, plp *Planifica
 }
 
 func (f FIFOEstrategy) manejarLiberacionDeProceso(plp *PlanificadorLargoPlazo) {
-	if Pmp.suspReadyState.Vacia() {
+	if plp.activo.Load() && Pmp.suspReadyState.Vacia() {
 		// chequea una copia del mismo, si puede irse lo desencola
 		if plp.newState.Vacia() {
 			return
 		}
 		proximoProceso := plp.newState.VizualizarProximo()
-		if plp.EnviarPedidoMemoria(proximoProceso) {
+		if plp.admitirEnMemoria(proximoProceso) {
 			plp.newState.SacarProximoProceso()
 			go plp.EnviarProcesoAReady(proximoProceso)
 			f.manejarLiberacionDeProceso(plp)
+		} else if proximoProceso.scriptInvalido {
+			plp.newState.SacarProximoProceso()
+			go plp.finalizarProcesoNuevo(proximoProceso)
+			f.manejarLiberacionDeProceso(plp)
 		}
 	}
 }
@@ -640,16 +960,74 @@ func (p PMCPEstrategy) manejarIngresoDeProceso(nuevoProceso *PCB, plp *Planifica
 }
 
 func (p PMCPEstrategy) manejarLiberacionDeProceso(plp *PlanificadorLargoPlazo) {
-	if Pmp.suspReadyState.Vacia() {
+	if plp.activo.Load() && Pmp.suspReadyState.Vacia() {
 		plp.newState.OrdenarPorPMC()
 		if plp.newState.Vacia() {
 			return
 		}
 		proximoProceso := plp.newState.VizualizarProximo()
-		if plp.EnviarPedidoMemoria(proximoProceso) {
+		if plp.admitirEnMemoria(proximoProceso) {
 			plp.newState.SacarProximoProceso()
 			go plp.EnviarProcesoAReady(proximoProceso)
 			p.manejarLiberacionDeProceso(plp)
+		} else if proximoProceso.scriptInvalido {
+			plp.newState.SacarProximoProceso()
+			go plp.finalizarProcesoNuevo(proximoProceso)
+			p.manejarLiberacionDeProceso(plp)
+		}
+	}
+}
+
+// Cantidad de procesos con memoria asignada (READY, EXEC y BLOCKED) y su límite
+type GradoMultiprogramacion struct {
+	limite    int // 0 = sin límite
+	enMemoria int
+	mu        sync.Mutex
+}
+
+var multiprogramacion GradoMultiprogramacion
+
+// Toma un lugar para el proceso, devuelve false si ya se alcanzó el límite
+func (g *GradoMultiprogramacion) reservar(proceso *PCB) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.limite > 0 && g.enMemoria >= g.limite {
+		clientUtils.Logger.Debug(fmt.Sprintf("## (%d) - Espera por grado de multiprogramación (%d)", proceso.PID, g.limite))
+		return false
+	}
+	g.enMemoria++
+	proceso.enMemoria.Store(true)
+	return true
+}
+
+// Devuelve el lugar del proceso, si es que tenía uno
+func (g *GradoMultiprogramacion) liberar(proceso *PCB) {
+	if !proceso.enMemoria.Swap(false) {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.enMemoria--
+}
+
+func (g *GradoMultiprogramacion) cambiarLimite(limite int) (anterior int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	anterior = g.limite
+	g.limite = limite
+	return anterior
+}
+
+// Cambia el grado de multiprogramación en caliente. Si aumenta, admite enseguida a los que esperaban
+func CambiarGradoMultiprogramacion(limite int) {
+	anterior := multiprogramacion.cambiarLimite(limite)
+	clientUtils.Logger.Info(fmt.Sprintf("## Grado de multiprogramación: %d -> %d", anterior, limite))
+	if limite == 0 || (anterior != 0 && limite > anterior) {
+		if !Pmp.suspReadyState.Vacia() {
+			Pmp.suspReadyEstrategy.manejarLiberacionDeProceso(&Pmp)
+		}
+		if Pmp.suspReadyState.Vacia() {
+			Plp.newAlgorithmEstrategy.manejarLiberacionDeProceso(&Plp)
 		}
 	}
 }
@@ -660,12 +1038,32 @@ type PlanificadorLargoPlazo struct {
 	blockedState          PCBList
 	newAlgorithmEstrategy NewAlgorithmEstrategy
 	pcp                   PlanificadorCortoPlazo
+	activo                atomic.Bool // mientras está detenido los procesos se acumulan en NEW
+}
+
+// Reanuda la admisión de procesos y admite los que quedaron esperando en NEW
+func (plp *PlanificadorLargoPlazo) Reanudar() {
+	if plp.activo.Swap(true) {
+		return
+	}
+	clientUtils.Logger.Info("## Planificación de Largo Plazo iniciada")
+	if Pmp.suspReadyState.Vacia() {
+		plp.newAlgorithmEstrategy.manejarLiberacionDeProceso(plp)
+	}
+}
+
+func (plp *PlanificadorLargoPlazo) Detener() {
+	if plp.activo.Swap(false) {
+		clientUtils.Logger.Info("## Planificación de Largo Plazo detenida")
+	}
 }
 
 func (plp *PlanificadorLargoPlazo) RecibirNuevoProceso(nuevoProceso *PCB) {
 	clientUtils.Logger.Info(fmt.Sprintf("## (%d) Se crea el proceso - Estado: NEW", nuevoProceso.PID))
 	nuevoProceso.timeInCurrentState = time.Now()
-	if plp.newState.Vacia() && Pmp.suspReadyState.Vacia() {
+	if !plp.activo.Load() {
+		plp.newState.Agregar(nuevoProceso)
+	} else if plp.newState.Vacia() && Pmp.suspReadyState.Vacia() {
 		plp.intentarInicializar(nuevoProceso)
 	} else {
 		plp.newAlgorithmEstrategy.manejarIngresoDeProceso(nuevoProceso, plp)
@@ -679,7 +1077,19 @@ func (plp *PlanificadorLargoPlazo) blockedTimer(proceso *PCB) {
 	if ok {
 		proceso.MT.blockedTime += proceso.timeInState()
 		Pmp.RecibirProcesoSuspblocked(proceso)
-		plp.EnviarSuspensionMemoria(proceso)
+		if !plp.EnviarSuspensionMemoria(proceso) {
+			// Memoria no pudo pasarlo a swap (por ejemplo, swap lleno): sigue ocupando memoria,
+			// así que vuelve a BLOCKED sin liberar su lugar. Si la IO terminó mientras tanto ya
+			// pasó a SUSP READY y se sigue como si se hubiera suspendido.
+			if _, ok := Pmp.suspBlockedState.BuscarYSacarPorPID(proceso.PID); ok {
+				clientUtils.Logger.Info(fmt.Sprintf("## (%d) Pasa del estado SUSP BLOCKED al estado BLOCKED", proceso.PID))
+				proceso.MT.suspBlockedTime += proceso.timeInState()
+				proceso.timeInCurrentState = time.Now()
+				plp.blockedState.Agregar(proceso)
+				return
+			}
+		}
+		multiprogramacion.liberar(proceso)
 		if Pmp.suspReadyState.Vacia() {
 			plp.newAlgorithmEstrategy.manejarLiberacionDeProceso(plp)
 		} else {
@@ -696,8 +1106,10 @@ func (plp *PlanificadorLargoPlazo) RecibirProcesoBlocked(proceso *PCB) {
 }
 
 func (plp *PlanificadorLargoPlazo) intentarInicializar(nuevoProceso *PCB) {
-	if plp.EnviarPedidoMemoria(nuevoProceso) {
+	if plp.admitirEnMemoria(nuevoProceso) {
 		plp.EnviarProcesoAReady(nuevoProceso)
+	} else if nuevoProceso.scriptInvalido {
+		plp.finalizarProcesoNuevo(nuevoProceso)
 	} else {
 		plp.newState.Agregar(nuevoProceso)
 	}
@@ -715,31 +1127,61 @@ func (plp *PlanificadorLargoPlazo) EnviarProcesoAReady(proceso *PCB) {
 	plp.pcp.RecibirProceso(proceso)
 }
 
+// Respuestas a las syscalls sincrónicas: el proceso sigue en la CPU o la tiene que dejar
+const (
+	SYSCALL_CONTINUAR = "CONTINUAR"
+	SYSCALL_BLOQUEADO = "BLOQUEADO"
+)
+
 func (plp *PlanificadorLargoPlazo) FinalizarProceso(proceso *PCB) {
+	plp.finalizarProcesoDesde(proceso, "EXEC")
+}
 
-	if plp.EnviarFinalizacionMemoria(proceso) {
+func (plp *PlanificadorLargoPlazo) finalizarProcesoDesde(proceso *PCB, estadoAnterior string) {
 
-		// Registramos el tiempo en el que el proceso entra en EXIT
-		proceso.timeInCurrentState = time.Now()
-		proceso.ME.exitCount++
+	if !plp.EnviarFinalizacionMemoria(proceso) {
+		// Para el Kernel termina igual: sus semáforos, mutex y los WAIT_PID que lo esperan no pueden quedar colgados
+		clientUtils.Logger.Error(fmt.Sprintf("Error: Memoria no aceptó finalizar el proceso PID %d", proceso.PID))
+	}
 
-		// Confirmamos la transición de EXEC → EXIT
-		clientUtils.Logger.Info(fmt.Sprintf("## (%d) Pasa del estado EXEC al estado EXIT", proceso.PID))
+	// Registramos el tiempo en el que el proceso entra en EXIT
+	proceso.timeInCurrentState = time.Now()
+	proceso.ME.exitCount++
 
-		// Se registra en la lista de EXIT para registrar el cambio de estado
-		plp.exitState.Agregar(proceso)
-		plp.loggearMetricas(proceso)
+	// Confirmamos la transición al estado EXIT
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) Pasa del estado %s al estado EXIT", proceso.PID, estadoAnterior))
 
-		if Pmp.suspReadyState.Vacia() {
-			plp.newAlgorithmEstrategy.manejarLiberacionDeProceso(plp)
-		} else {
-			Pmp.suspReadyEstrategy.manejarLiberacionDeProceso(&Pmp)
-		}
+	// Se registra en la lista de EXIT para registrar el cambio de estado
+	plp.exitState.Agregar(proceso)
+	multiprogramacion.liberar(proceso)
+	liberarRecursos(proceso)
+	plp.loggearMetricas(proceso)
+	plp.registrarFinalizacion(proceso)
 
+	if Pmp.suspReadyState.Vacia() {
+		plp.newAlgorithmEstrategy.manejarLiberacionDeProceso(plp)
 	} else {
-		// Logueamos el error si Memoria rechazó la finalización
+		Pmp.suspReadyEstrategy.manejarLiberacionDeProceso(&Pmp)
+	}
+}
+
+// Un proceso en NEW todavía no tiene memoria asignada, así que no hace falta avisarle a Memoria,
+// salvo que venga de un FORK y ya comparta las páginas de su padre
+func (plp *PlanificadorLargoPlazo) finalizarProcesoNuevo(proceso *PCB) {
+	if proceso.forkeado && !plp.EnviarFinalizacionMemoria(proceso) {
 		clientUtils.Logger.Error(fmt.Sprintf("Error: Memoria no aceptó finalizar el proceso PID %d", proceso.PID))
 	}
+
+	proceso.MT.newTime += proceso.timeInState()
+	proceso.timeInCurrentState = time.Now()
+	proceso.ME.exitCount++
+
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) Pasa del estado NEW al estado EXIT", proceso.PID))
+
+	plp.exitState.Agregar(proceso)
+	liberarRecursos(proceso)
+	plp.loggearMetricas(proceso)
+	plp.registrarFinalizacion(proceso)
 }
 
 func (plp *PlanificadorLargoPlazo) loggearMetricas(proceso *PCB) {
@@ -755,6 +1197,56 @@ func (plp *PlanificadorLargoPlazo) loggearMetricas(proceso *PCB) {
 		proceso.ME.suspReadyCount, proceso.MT.suspReadyTime,
 		proceso.ME.suspBlockedCount, proceso.MT.suspBlockedTime,
 		proceso.ME.exitCount, proceso.MT.exitTime))
+
+	if proceso.motivoFinalizacion != "" {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Motivo de finalización: %s", proceso.PID, proceso.motivoFinalizacion))
+	}
+
+	if proceso.ME.recursoCount > 0 {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Métricas de recursos: BLOCKED %d %.2f", proceso.PID, proceso.ME.recursoCount, proceso.MT.recursoTime))
+	}
+
+	if _, esMLFQ := plp.pcp.schedulerEstrategy.(MLFQScheduler); esMLFQ {
+		plp.loggearMetricasMLFQ(proceso)
+	}
+}
+
+func (plp *PlanificadorLargoPlazo) loggearMetricasMLFQ(proceso *PCB) {
+	proceso.acumularTiempoEnNivel()
+
+	niveles := ""
+	colas := ""
+	for nivel := range globalskernel.KernelConfig.MlfqNiveles {
+		var ingresos uint
+		var tiempo float64
+		if nivel < len(proceso.MN.ingresos) {
+			ingresos = proceso.MN.ingresos[nivel]
+			tiempo = proceso.MN.tiempos[nivel]
+		}
+		if nivel > 0 {
+			niveles += ", "
+			colas += ", "
+		}
+		niveles += fmt.Sprintf("N%d %d %.2f", nivel, ingresos, tiempo)
+		colas += fmt.Sprintf("N%d %d", nivel, plp.pcp.readyState.ContarPorNivel(nivel))
+	}
+
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Métricas MLFQ: %s - Colas READY: %s", proceso.PID, niveles, colas))
+}
+
+// Reserva un lugar del grado de multiprogramación y pide el espacio a Memoria
+func (plp *PlanificadorLargoPlazo) admitirEnMemoria(proceso *PCB) bool {
+	if !multiprogramacion.reservar(proceso) {
+		return false
+	}
+	if proceso.forkeado {
+		return true
+	}
+	if plp.EnviarPedidoMemoria(proceso) {
+		return true
+	}
+	multiprogramacion.liberar(proceso)
+	return false
 }
 
 // pedido de inicialización de proceso devuelve si Memoria tiene espacio suficiente para inicializarlo
@@ -786,6 +1278,10 @@ func (plp *PlanificadorLargoPlazo) EnviarPedidoMemoria(nuevoProceso *PCB) bool {
 
 	if resp == nil {
 		clientUtils.Logger.Warn(fmt.Sprintf("Error de conexión al tratar de inicializar el proceso PID %d (respuesta nula)", nuevoProceso.PID))
+	} else if resp.StatusCode == http.StatusUnprocessableEntity {
+		motivo, _ := io.ReadAll(resp.Body)
+		clientUtils.Logger.Error(fmt.Sprintf("Memoria rechazó el pseudocódigo del proceso PID %d: %s", nuevoProceso.PID, strings.TrimSpace(string(motivo))))
+		nuevoProceso.scriptInvalido = true
 	} else {
 		clientUtils.Logger.Warn(fmt.Sprintf("Memoria rechazó la iniciacion del proceso PID %d por espacio insuficiente.", nuevoProceso.PID))
 	}
@@ -820,7 +1316,7 @@ func (plp *PlanificadorLargoPlazo) EnviarFinalizacionMemoria(procesoTernminado *
 	return false
 }
 
-func (plp *PlanificadorLargoPlazo) EnviarSuspensionMemoria(proceso *PCB) {
+func (plp *PlanificadorLargoPlazo) EnviarSuspensionMemoria(proceso *PCB) bool {
 	valores := []string{strconv.Itoa(int(proceso.PID))}
 	paquete := clientUtils.Paquete{Valores: valores}
 
@@ -833,7 +1329,7 @@ func (plp *PlanificadorLargoPlazo) EnviarSuspensionMemoria(proceso *PCB) {
 	resp := clientUtils.EnviarPaqueteConRespuesta(ip, puerto, endpoint, paquete)
 	if resp != nil && resp.StatusCode == http.StatusOK {
 		clientUtils.Logger.Info(fmt.Sprintf("Memoria envió Proceso PID %d a swap correctamente", proceso.PID))
-
+		return true
 	}
 
 	//Si no responde con 200 OK, lo logueamos como advertencia
@@ -842,6 +1338,7 @@ func (plp *PlanificadorLargoPlazo) EnviarSuspensionMemoria(proceso *PCB) {
 	} else if resp.StatusCode != http.StatusOK {
 		clientUtils.Logger.Info(fmt.Sprintf("Memoria rechazo la solicitud de swap del proceso PID %d. Status: %s", proceso.PID, resp.Status))
 	}
+	return false
 }
 
 // ------------ PLANIFICADOR CORTO PLAZO -----------------------------------------
@@ -849,6 +1346,10 @@ func (plp *PlanificadorLargoPlazo) EnviarSuspensionMemoria(proceso *PCB) {
 type SchedulerEstrategy interface {
 	selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo)
 	intentarDesalojo(pcp *PlanificadorCortoPlazo, proceso *PCB)
+	// quantum en milisegundos para la próxima ráfaga, 0 si el algoritmo no usa quantum
+	calcularQuantum(proceso *PCB) float64
+	// se llama cada vez que el proceso deja la CPU, con el motivo de la devolución
+	registrarDevolucion(proceso *PCB, motivo string)
 }
 
 type FIFOScheduler struct {
@@ -868,6 +1369,13 @@ func (f FIFOScheduler) selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo) {
 func (f FIFOScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, proceso *PCB) {
 }
 
+func (f FIFOScheduler) calcularQuantum(proceso *PCB) float64 {
+	return 0
+}
+
+func (f FIFOScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+}
+
 type SJFScheduler struct {
 }
 
@@ -885,6 +1393,13 @@ func (s SJFScheduler) selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo) {
 func (s SJFScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, proceso *PCB) {
 }
 
+func (s SJFScheduler) calcularQuantum(proceso *PCB) float64 {
+	return 0
+}
+
+func (s SJFScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+}
+
 type SRTScheduler struct {
 }
 
@@ -909,46 +1424,214 @@ func (s SRTScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, procesoNuevo
 		return
 	}
 	if procesoNuevo.estimacion < (proceso.estimacion - proceso.timeInState()) {
-		//buscar la cpu que tenga ese PID
-		cpu, ok := cpusOcupadas.BuscarPorPIDEnEjecucion(proceso.PID)
-		if !ok {
-			clientUtils.Logger.Error(fmt.Sprintf("Error al encontrar CPU con proceso PID %d en ejecución", proceso.PID))
-			return
-		}
-		proceso.estaSiendoDesalojado.Store(true)
-		procesoNuevo.pidioDesalojo.Store(true)
-		go cpu.enviarInterrupcion("DESALOJO")
-		<-cpu.sem_interrupcionAtendida
-		procesoSelecionado, ok := pcp.readyState.BuscarYSacarPorPID(procesoNuevo.PID)
-		if !ok {
-			clientUtils.Logger.Error(fmt.Sprintf("Error al encontrar el proceso PID %d en READY", procesoNuevo.PID))
-			return
-		}
-		procesoNuevo.pidioDesalojo.Store(false)
-		pcp.ejecutarConDesalojo(procesoSelecionado, cpu)
+		pcp.desalojar(proceso, procesoNuevo)
 	}
 }
 
-type PlanificadorCortoPlazo struct {
-	readyState         PCBList
-	execState          PCBList
-	schedulerEstrategy SchedulerEstrategy
+func (s SRTScheduler) calcularQuantum(proceso *PCB) float64 {
+	return 0
 }
 
-func (pcp *PlanificadorCortoPlazo) RecibirProceso(proceso *PCB) {
-	proceso.timeInCurrentState = time.Now()
-	proceso.ME.readyCount++
-	pcp.readyState.Agregar(proceso)
+func (s SRTScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+}
 
-	if proceso.estaSiendoDesalojado.Load() {
-		proceso.estaSiendoDesalojado.Store(false)
-	} else if cpusLibres.Vacia() {
-		go pcp.schedulerEstrategy.intentarDesalojo(pcp, proceso)
-	}
+type RRScheduler struct {
+}
+
+func (r RRScheduler) selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo) {
+	if pcp.readyState.Vacia() {
+		// si no usaste el recurso lo liberas
+		sem_cpusLibres <- 1
+		return
+	}
+	proximo, ok := pcp.readyState.SacarProximoProceso()
+	if ok {
+		proximo.MT.readyTime += proximo.timeInState()
+		pcp.ejecutar(proximo)
+	}
+}
+
+func (r RRScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, proceso *PCB) {
+}
+
+func (r RRScheduler) calcularQuantum(proceso *PCB) float64 {
+	return float64(globalskernel.KernelConfig.Quantum)
+}
+
+func (r RRScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+}
+
+// Motivos de devolución en los que el proceso deja la CPU para bloquearse
+func esMotivoBloqueante(motivo string) bool {
+	switch motivo {
+	case "IO", "DUMP_MEMORY", "WAIT_PID", "WAIT", "MUTEX_LOCK", "PAGE_FAULT":
+		return true
+	}
+	return false
+}
+
+// VRR: igual que RR, pero los procesos que vuelven de un bloqueo sin haber
+// consumido todo su quantum tienen prioridad y ejecutan con el quantum restante
+type VRRScheduler struct {
+}
+
+func (v VRRScheduler) selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo) {
+	if pcp.readyState.Vacia() {
+		// si no usaste el recurso lo liberas
+		sem_cpusLibres <- 1
+		return
+	}
+	proximo, ok := pcp.readyState.SacarProximoConQuantumRestante()
+	if !ok {
+		proximo, ok = pcp.readyState.SacarProximoProceso()
+	}
+	if ok {
+		proximo.MT.readyTime += proximo.timeInState()
+		pcp.ejecutar(proximo)
+	}
+}
+
+func (v VRRScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, proceso *PCB) {
+}
+
+func (v VRRScheduler) calcularQuantum(proceso *PCB) float64 {
+	if proceso.quantumRestante > 0 {
+		return proceso.quantumRestante
+	}
+	return float64(globalskernel.KernelConfig.Quantum)
+}
+
+func (v VRRScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+	// Solo conserva el quantum quien se bloquea, el que lo agota o es desalojado vuelve a la cola normal
+	if esMotivoBloqueante(motivo) {
+		restante := proceso.quantumAsignado - proceso.tiempoEnRafaga()
+		if restante > 0 {
+			proceso.quantumRestante = restante
+			return
+		}
+	}
+	proceso.quantumRestante = 0
+}
+
+// MLFQ: colas por nivel, cada una con su quantum y algoritmo. Se baja de nivel al agotar
+// el quantum, se sube al volver de un bloqueo y por envejecimiento en READY
+type MLFQScheduler struct {
+}
+
+func (m MLFQScheduler) selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo) {
+	if pcp.readyState.Vacia() {
+		// si no usaste el recurso lo liberas
+		sem_cpusLibres <- 1
+		return
+	}
+
+	if globalskernel.KernelConfig.MlfqAging > 0 {
+		for _, proceso := range pcp.readyState.EnvejecerNiveles(float64(globalskernel.KernelConfig.MlfqAging)) {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Envejece al nivel %d", proceso.PID, proceso.nivel))
+		}
+	}
+
+	for nivel, config := range globalskernel.KernelConfig.MlfqNiveles {
+		proximo, ok := pcp.readyState.SacarProximoDeNivel(nivel, config.Algoritmo == "SJF")
+		if ok {
+			proximo.MT.readyTime += proximo.timeInState()
+			pcp.ejecutar(proximo)
+			return
+		}
+	}
+}
+
+func (m MLFQScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, proceso *PCB) {
+}
+
+func (m MLFQScheduler) calcularQuantum(proceso *PCB) float64 {
+	return float64(globalskernel.KernelConfig.MlfqNiveles[proceso.nivel].Quantum)
+}
+
+func (m MLFQScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+	ultimoNivel := len(globalskernel.KernelConfig.MlfqNiveles) - 1
+	if motivo == "FIN_QUANTUM" && proceso.nivel < ultimoNivel {
+		proceso.cambiarNivel(proceso.nivel + 1)
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Baja al nivel %d", proceso.PID, proceso.nivel))
+	} else if esMotivoBloqueante(motivo) && proceso.nivel > 0 {
+		proceso.cambiarNivel(proceso.nivel - 1)
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Sube al nivel %d", proceso.PID, proceso.nivel))
+	}
+}
+
+// PRIORIDADES: ejecuta el proceso de mayor prioridad y desaloja al de menor prioridad en EXEC
+// cuando llega uno mejor. Con priority_aging > 0 la prioridad mejora mientras espera en READY
+type PrioridadesScheduler struct {
+}
+
+func (p PrioridadesScheduler) selecionarProximoAEjecutar(pcp *PlanificadorCortoPlazo) {
+	if pcp.readyState.Vacia() {
+		// si no usaste el recurso lo liberas
+		sem_cpusLibres <- 1
+		return
+	}
+
+	if globalskernel.KernelConfig.PriorityAging > 0 {
+		for _, proceso := range pcp.readyState.EnvejecerPrioridades(float64(globalskernel.KernelConfig.PriorityAging)) {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Envejece a prioridad %d", proceso.PID, proceso.Prioridad))
+		}
+	}
+
+	proximo, ok := pcp.readyState.SacarProcesoConMayorPrioridad()
+	if ok {
+		// al ejecutar pierde lo ganado por envejecimiento
+		proximo.Prioridad = proximo.prioridadBase
+		proximo.MT.readyTime += proximo.timeInState()
+		pcp.ejecutar(proximo)
+	}
+}
+
+func (p PrioridadesScheduler) intentarDesalojo(pcp *PlanificadorCortoPlazo, procesoNuevo *PCB) {
+	proceso, ok := pcp.execState.BuscarProcesoConMenorPrioridad()
+	if !ok {
+		clientUtils.Logger.Error("Error al buscar proceso con menor prioridad")
+		return
+	}
+	if procesoNuevo.Prioridad < proceso.Prioridad {
+		pcp.desalojar(proceso, procesoNuevo)
+	}
+}
+
+func (p PrioridadesScheduler) calcularQuantum(proceso *PCB) float64 {
+	return 0
+}
+
+func (p PrioridadesScheduler) registrarDevolucion(proceso *PCB, motivo string) {
+}
+
+type PlanificadorCortoPlazo struct {
+	readyState         PCBList
+	execState          PCBList
+	schedulerEstrategy SchedulerEstrategy
+}
+
+func (pcp *PlanificadorCortoPlazo) RecibirProceso(proceso *PCB) {
+	proceso.timeInCurrentState = time.Now()
+	proceso.ME.readyCount++
+	pcp.readyState.Agregar(proceso)
+
+	if proceso.estaSiendoDesalojado.Load() {
+		proceso.estaSiendoDesalojado.Store(false)
+	} else if cpusLibres.Vacia() {
+		go pcp.schedulerEstrategy.intentarDesalojo(pcp, proceso)
+	}
 	<-sem_cpusLibres
+	// Un proceso finalizado desde READY dejó su espera de CPU sin proceso, la CPU pasa al próximo que espere
+	if n := esperasSinProceso.Load(); n > 0 && pcp.readyState.Vacia() && esperasSinProceso.CompareAndSwap(n, n-1) {
+		sem_cpusLibres <- 1
+		return
+	}
 	pcp.schedulerEstrategy.selecionarProximoAEjecutar(pcp)
 }
 
+// Procesos sacados de READY por una finalización mientras esperaban CPU en RecibirProceso
+var esperasSinProceso atomic.Int32
+
 func (pcp *PlanificadorCortoPlazo) ejecutar(proceso *PCB) {
 	CPUlibre := cpusLibres.SacarProxima()
 	// Log de cambio de estado READY -> EXEC
@@ -958,10 +1641,38 @@ func (pcp *PlanificadorCortoPlazo) ejecutar(proceso *PCB) {
 	proceso.timeInCurrentState = time.Now()
 	proceso.ME.execCount++
 	pcp.execState.Agregar(proceso)
-	CPUlibre.PIDenEjecucion = proceso.PID
+	CPUlibre.PIDenEjecucion.Store(uint64(proceso.PID))
 
 	cpusOcupadas.Agregar(CPUlibre)
-	CPUlibre.enviarProceso(proceso.PID, proceso.PC)
+	CPUlibre.enviarProceso(proceso.PID, proceso.PC, proceso.Registros)
+	pcp.iniciarQuantum(proceso, CPUlibre)
+}
+
+// Interrumpe al proceso en EXEC y pone a ejecutar al nuevo en la misma CPU
+func (pcp *PlanificadorCortoPlazo) desalojar(proceso *PCB, procesoNuevo *PCB) {
+	//buscar la cpu que tenga ese PID
+	cpu, ok := cpusOcupadas.BuscarPorPIDEnEjecucion(proceso.PID)
+	if !ok {
+		clientUtils.Logger.Error(fmt.Sprintf("Error al encontrar CPU con proceso PID %d en ejecución", proceso.PID))
+		return
+	}
+	proceso.estaSiendoDesalojado.Store(true)
+	procesoNuevo.pidioDesalojo.Store(true)
+	go cpu.enviarInterrupcion("DESALOJO", proceso.PID)
+	if !<-cpu.sem_interrupcionAtendida {
+		// el nuevo sigue en READY esperando una CPU como cualquier otro
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - La CPU descartó el desalojo, el proceso %d espera una CPU libre", proceso.PID, procesoNuevo.PID))
+		procesoNuevo.pidioDesalojo.Store(false)
+		return
+	}
+	procesoSelecionado, ok := pcp.readyState.BuscarYSacarPorPID(procesoNuevo.PID)
+	if !ok {
+		clientUtils.Logger.Error(fmt.Sprintf("Error al encontrar el proceso PID %d en READY", procesoNuevo.PID))
+		cpu.soltar(false)
+		return
+	}
+	procesoNuevo.pidioDesalojo.Store(false)
+	pcp.ejecutarConDesalojo(procesoSelecionado, cpu)
 }
 
 func (pcp *PlanificadorCortoPlazo) ejecutarConDesalojo(proceso *PCB, cpu *Cpu) {
@@ -969,9 +1680,29 @@ func (pcp *PlanificadorCortoPlazo) ejecutarConDesalojo(proceso *PCB, cpu *Cpu) {
 	proceso.timeInCurrentState = time.Now()
 	proceso.ME.execCount++
 	pcp.execState.Agregar(proceso)
-	cpu.PIDenEjecucion = proceso.PID
+	cpu.PIDenEjecucion.Store(uint64(proceso.PID))
+
+	cpu.enviarProceso(proceso.PID, proceso.PC, proceso.Registros)
+	pcp.iniciarQuantum(proceso, cpu)
+}
+
+// Arma el timer que interrumpe a la CPU cuando el proceso agota su quantum
+func (pcp *PlanificadorCortoPlazo) iniciarQuantum(proceso *PCB, cpu *Cpu) {
+	proceso.inicioRafaga = time.Now()
+	quantum := pcp.schedulerEstrategy.calcularQuantum(proceso)
+	proceso.quantumAsignado = quantum
+	if quantum <= 0 {
+		return
+	}
 
-	cpu.enviarProceso(proceso.PID, proceso.PC)
+	pid := proceso.PID
+	proceso.mutexQuantum.Lock()
+	defer proceso.mutexQuantum.Unlock()
+	proceso.timerQuantum = time.AfterFunc(time.Duration(quantum*float64(time.Millisecond)), func() {
+		if cpu.PIDenEjecucion.Load() == uint64(pid) {
+			cpu.enviarInterrupcion("FIN_QUANTUM", pid)
+		}
+	})
 }
 
 func (pcp *PlanificadorCortoPlazo) EnviarProcesoABlocked(proceso *PCB) {
@@ -1001,7 +1732,7 @@ func (f SuspFIFOEstrategy) manejarLiberacionDeProceso(pmp *PlanificadorMedianoPl
 		return
 	}
 	proximoProceso := Pmp.suspReadyState.VizualizarProximo()
-	if Pmp.EnviarDesSuspensionPedidoMemoria(proximoProceso) {
+	if Pmp.readmitirEnMemoria(proximoProceso) {
 		Pmp.suspReadyState.SacarProximoProceso()
 		go Pmp.EnviarProcesoAReady(proximoProceso)
 		f.manejarLiberacionDeProceso(pmp)
@@ -1027,7 +1758,7 @@ func (p SuspPMCPEstrategy) manejarLiberacionDeProceso(pmp *PlanificadorMedianoPl
 	}
 	Pmp.suspReadyState.OrdenarPorPMC()
 	proximoProceso := Pmp.suspReadyState.VizualizarProximo()
-	if Pmp.EnviarDesSuspensionPedidoMemoria(proximoProceso) {
+	if Pmp.readmitirEnMemoria(proximoProceso) {
 		Pmp.suspReadyState.SacarProximoProceso()
 		go Pmp.EnviarProcesoAReady(proximoProceso)
 		p.manejarLiberacionDeProceso(pmp)
@@ -1060,7 +1791,7 @@ func (pmp *PlanificadorMedianoPlazo) EnviarProcesoASuspReady(proceso *PCB) {
 }
 
 func (pmp *PlanificadorMedianoPlazo) intentarInicializar(proceso *PCB) {
-	if pmp.EnviarDesSuspensionPedidoMemoria(proceso) {
+	if pmp.readmitirEnMemoria(proceso) {
 		pmp.EnviarProcesoAReady(proceso)
 	} else {
 		pmp.suspReadyState.Agregar(proceso)
@@ -1074,6 +1805,18 @@ func (pmp *PlanificadorMedianoPlazo) EnviarProcesoAReady(proceso *PCB) {
 	Plp.pcp.RecibirProceso(proceso)
 }
 
+// Igual que admitirEnMemoria, pero para un proceso que vuelve de swap
+func (pmp *PlanificadorMedianoPlazo) readmitirEnMemoria(proceso *PCB) bool {
+	if !multiprogramacion.reservar(proceso) {
+		return false
+	}
+	if pmp.EnviarDesSuspensionPedidoMemoria(proceso) {
+		return true
+	}
+	multiprogramacion.liberar(proceso)
+	return false
+}
+
 func (pmp *PlanificadorMedianoPlazo) EnviarDesSuspensionPedidoMemoria(proceso *PCB) bool {
 	// Creamos el contenido del paquete con lo que la Memoria necesita:
 	// PID, Ruta al pseudocódigo, y Tamaño del proceso
@@ -1122,25 +1865,86 @@ func RegistrarCpu(w http.ResponseWriter, r *http.Request) {
 		Identificador:            paquete.Valores[0],
 		Ip:                       paquete.Valores[1],
 		Puerto:                   puerto,
-		sem_interrupcionAtendida: make(chan struct{}),
+		sem_interrupcionAtendida: make(chan bool),
 	}
 
 	cpusLibres.Agregar(&nuevaCpu)
 	sem_cpusLibres <- 1
-	clientUtils.Logger.Info(fmt.Sprintf("CPU registrada: %+v", nuevaCpu))
+	clientUtils.Logger.Info(fmt.Sprintf("CPU registrada: %s (%s:%d)", nuevaCpu.Identificador, nuevaCpu.Ip, nuevaCpu.Puerto))
+	w.WriteHeader(http.StatusOK)
+}
+
+// La CPU avisa que descartó una interrupción porque el proceso al que iba ya no estaba ejecutando.
+// Si era un desalojo que nadie atendió, el que lo pidió deja de esperar esa CPU
+func InterrupcionDescartada(w http.ResponseWriter, r *http.Request) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 3 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, err := strconv.Atoi(paquete.Valores[2])
+	if err != nil {
+		clientUtils.Logger.Error("Error al parsear el PID de la interrupción descartada")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
 	w.WriteHeader(http.StatusOK)
+	if paquete.Valores[1] != "DESALOJO" {
+		return
+	}
+
+	cpu, ok := cpusOcupadas.BuscarPorID(paquete.Valores[0])
+	if !ok {
+		cpu, ok = cpusLibres.BuscarPorID(paquete.Valores[0])
+	}
+	muProcesos.Lock()
+	proceso, existe := tablaProcesos[uint(pid)]
+	muProcesos.Unlock()
+	if !ok || !existe {
+		return
+	}
+	// Si volvió a EXEC, el desalojo viejo ya se resolvió cuando dejó la CPU
+	if _, ejecutando := Plp.pcp.execState.BuscarPorPID(uint(pid)); ejecutando {
+		return
+	}
+	if proceso.estaSiendoDesalojado.Swap(false) {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Desalojo descartado por la CPU %s", pid, cpu.Identificador))
+		go func() { cpu.sem_interrupcionAtendida <- false }()
+	}
 }
 
 const (
 	CPU_ID = iota
 	PC
+	REGISTROS
 	MOTIVO_DEVOLUCION
 	FILE_PATH
 	TAM_PROC
-	NOMBRE_IO = FILE_PATH
-	TIME      = TAM_PROC
+	PRIORIDAD
+	NOMBRE_IO      = FILE_PATH
+	TIME           = TAM_PROC
+	PID_OBJETIVO   = FILE_PATH
+	NOMBRE_RECURSO = FILE_PATH
+	NRO_PAGINA     = FILE_PATH
+	REGISTRO_FORK  = FILE_PATH
+	TAMANIO_NUEVO  = FILE_PATH
+	DIRECCION_SEG  = FILE_PATH
+	MOTIVO_SEG     = TAM_PROC
+	DETALLE_ERROR  = FILE_PATH
 )
 
+// Las syscalls sincrónicas dejan al proceso en la CPU mientras el Kernel las atiende.
+// Un KILL sobre el propio PID se trata como un EXIT
+func esSyscallSincronica(proceso *PCB, valores []string) bool {
+	switch valores[MOTIVO_DEVOLUCION] {
+	case "INIT_PROC", "FORK", "WAIT_PID", "BRK", "WAIT", "SIGNAL", "MUTEX_LOCK", "MUTEX_UNLOCK", "SHM_CREATE", "SHM_ATTACH", "SHM_DETACH":
+		return true
+	case "KILL":
+		return len(valores) == PID_OBJETIVO+1 && valores[PID_OBJETIVO] != strconv.Itoa(int(proceso.PID))
+	}
+	return false
+}
+
 // ENDPOINT PARA LAS SYSCALLS
 func ResultadoProcesos(w http.ResponseWriter, r *http.Request) {
 
@@ -1154,9 +1958,10 @@ func ResultadoProcesos(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// saco el proceso de EXEC y acumulo cuanto tiempo estuvo ejecutando
-	proceso, ok := Plp.pcp.execState.BuscarYSacarPorPID(cpu.PIDenEjecucion)
+	pidEnEjecucion := uint(cpu.PIDenEjecucion.Load())
+	proceso, ok := Plp.pcp.execState.BuscarYSacarPorPID(pidEnEjecucion)
 	if !ok {
-		clientUtils.Logger.Error(fmt.Sprintf("Error al encontrar el proceso en ejecucion PID:%d, CPU: %s, PC: %s", cpu.PIDenEjecucion, cpu.Identificador, respuesta.Valores[PC]))
+		clientUtils.Logger.Error(fmt.Sprintf("Error al encontrar el proceso en ejecucion PID:%d, CPU: %s, PC: %s", pidEnEjecucion, cpu.Identificador, respuesta.Valores[PC]))
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
@@ -1172,6 +1977,34 @@ func ResultadoProcesos(w http.ResponseWriter, r *http.Request) {
 	}
 	proceso.PC = uint(pcActualizado)
 
+	contexto, err := registros.Deserializar(respuesta.Valores[REGISTROS])
+	if err != nil {
+		clientUtils.Logger.Error("Error al parsear los registros del proceso")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	proceso.Registros = contexto
+
+	// Las syscalls sincrónicas no sacan al proceso de la CPU, así que su quantum sigue corriendo
+	sincronica := esSyscallSincronica(proceso, respuesta.Valores)
+	if !sincronica {
+		proceso.detenerQuantum()
+		Plp.pcp.schedulerEstrategy.registrarDevolucion(proceso, respuesta.Valores[MOTIVO_DEVOLUCION])
+	}
+
+	// Si se pidió finalizarlo mientras ejecutaba, termina acá sin importar el motivo
+	if proceso.finalizacionPedida.Load() {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Finalizado por pedido externo", proceso.PID))
+		go Plp.FinalizarProceso(proceso)
+		if sincronica {
+			cpu.liberarTrasSyscall(proceso)
+		} else {
+			cpu.liberar(proceso)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	//--------------------------- Manejo de las distintas syscalls ----------------------------------
 
 	if respuesta.Valores[MOTIVO_DEVOLUCION] == "INIT_PROC" {
@@ -1182,33 +2015,173 @@ func ResultadoProcesos(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
 			return
 		}
-		proceso.timeInCurrentState = time.Now()
-		Plp.pcp.execState.Agregar(proceso)
-		go cpu.enviarFinInitProc()
+		// La prioridad es opcional, si no viene el hijo arranca con la máxima
+		prioridad := 0
+		if len(respuesta.Valores) > PRIORIDAD {
+			prioridad, err = strconv.Atoi(respuesta.Valores[PRIORIDAD])
+			if err != nil {
+				clientUtils.Logger.Error("Error al parsear prioridad del proceso")
+				http.Error(w, "Bad Request", http.StatusBadRequest)
+				return
+			}
+		}
+		hijo := nuevoPCB(respuesta.Valores[FILE_PATH], uint(tamProc), prioridad, int(proceso.PID))
+		cpu.continuarTrasSyscall(proceso, strconv.Itoa(int(hijo.PID)))
+
+		go Plp.RecibirNuevoProceso(hijo)
 
-		go IniciarProceso(respuesta.Valores[FILE_PATH], uint(tamProc))
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "FORK" {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: FORK", proceso.PID))
+		if len(respuesta.Valores) <= REGISTRO_FORK || !registros.EsRegistro(respuesta.Valores[REGISTRO_FORK]) {
+			clientUtils.Logger.Error("Error al parsear el registro del FORK")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if hijo := forkProceso(proceso, respuesta.Valores[REGISTRO_FORK]); hijo != nil {
+			cpu.continuarTrasSyscall(proceso, strconv.Itoa(int(hijo.PID)))
+			go Plp.RecibirNuevoProceso(hijo)
+		} else {
+			cpu.continuarTrasSyscall(proceso, "-1")
+		}
 
 	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "EXIT" {
 		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: EXIT", proceso.PID))
 		go Plp.FinalizarProceso(proceso)
-		cpusLibres.Agregar(cpusOcupadas.SacarPorID(cpu.Identificador))
-		sem_cpusLibres <- 1
+		cpu.liberar(proceso)
+
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "SEG_FAULT" {
+		// Acceso fuera del proceso o sin permiso: la CPU ya lo soltó y se finaliza con el motivo
+		detalle := "acceso inválido"
+		if len(respuesta.Valores) > MOTIVO_SEG {
+			detalle = fmt.Sprintf("Dirección: %s - %s", respuesta.Valores[DIRECCION_SEG], respuesta.Valores[MOTIVO_SEG])
+		}
+		clientUtils.Logger.Error(fmt.Sprintf("## (%d) - SEG_FAULT - %s", proceso.PID, detalle))
+		proceso.motivoFinalizacion = "SEG_FAULT: " + detalle
+		go Plp.FinalizarProceso(proceso)
+		cpu.liberar(proceso)
+
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "ERROR_EJECUCION" {
+		// La CPU no pudo ejecutar la instrucción (operandos inválidos o error de Memoria) y ya lo soltó
+		detalle := "instrucción inválida"
+		if len(respuesta.Valores) > DETALLE_ERROR {
+			detalle = respuesta.Valores[DETALLE_ERROR]
+		}
+		clientUtils.Logger.Error(fmt.Sprintf("## (%d) - ERROR_EJECUCION - %s", proceso.PID, detalle))
+		proceso.motivoFinalizacion = "ERROR_EJECUCION: " + detalle
+		go Plp.FinalizarProceso(proceso)
+		cpu.liberar(proceso)
+
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "KILL" {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: KILL", proceso.PID))
+		if !sincronica {
+			// se finalizó a sí mismo
+			go Plp.FinalizarProceso(proceso)
+			cpu.liberar(proceso)
+		} else {
+			pidObjetivo, err := strconv.Atoi(respuesta.Valores[PID_OBJETIVO])
+			if err != nil || pidObjetivo < 0 {
+				clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - KILL con PID inválido: %s", proceso.PID, respuesta.Valores[PID_OBJETIVO]))
+			} else if err := FinalizarProcesoPorPID(uint(pidObjetivo)); err != nil {
+				clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - KILL: %s", proceso.PID, err))
+			}
+			cpu.continuarTrasSyscall(proceso)
+		}
+
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "WAIT_PID" {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: WAIT_PID", proceso.PID))
+		pidHijo, err := -1, error(nil)
+		if len(respuesta.Valores) > PID_OBJETIVO {
+			pidHijo, err = strconv.Atoi(respuesta.Valores[PID_OBJETIVO])
+		}
+		if err != nil || pidHijo < 0 || !Plp.esperarHijo(proceso, uint(pidHijo)) {
+			// No hay un hijo vivo con ese PID, así que no hay nada que esperar
+			cpu.continuarTrasSyscall(proceso)
+		} else {
+			cpu.liberarTrasSyscall(proceso)
+		}
+
+	} else if esSyscallDeRecurso(respuesta.Valores[MOTIVO_DEVOLUCION]) {
+		motivo := respuesta.Valores[MOTIVO_DEVOLUCION]
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: %s", proceso.PID, motivo))
+		nombre := ""
+		if len(respuesta.Valores) > NOMBRE_RECURSO {
+			nombre = respuesta.Valores[NOMBRE_RECURSO]
+		}
+		switch atenderSyscallDeRecurso(proceso, motivo, nombre) {
+		case SYSCALL_CONTINUAR:
+			cpu.continuarTrasSyscall(proceso)
+		case SYSCALL_BLOQUEADO:
+			cpu.liberarTrasSyscall(proceso)
+		default:
+			// recurso inexistente o mal usado
+			cpu.liberarTrasSyscall(proceso)
+			go Plp.FinalizarProceso(proceso)
+		}
+
+	} else if esSyscallDeMemoriaCompartida(respuesta.Valores[MOTIVO_DEVOLUCION]) {
+		motivo := respuesta.Valores[MOTIVO_DEVOLUCION]
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: %s", proceso.PID, motivo))
+		if EnviarSyscallMemoriaCompartida(proceso.PID, motivo, respuesta.Valores[FILE_PATH:]) {
+			cpu.continuarTrasSyscall(proceso)
+		} else {
+			// segmento inexistente, rango de direcciones ocupado o sin memoria, igual que un recurso mal usado
+			cpu.liberarTrasSyscall(proceso)
+			go Plp.FinalizarProceso(proceso)
+		}
+
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "BRK" {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: BRK", proceso.PID))
+		tamanio := -1
+		if len(respuesta.Valores) > TAMANIO_NUEVO {
+			if valor, err := strconv.Atoi(respuesta.Valores[TAMANIO_NUEVO]); err == nil {
+				tamanio = valor
+			}
+		}
+		if tamanio < 0 {
+			clientUtils.Logger.Error(fmt.Sprintf("## (%d) - BRK con tamaño inválido: %v", proceso.PID, respuesta.Valores[FILE_PATH:]))
+		}
+		if tamanio >= 0 && EnviarCambioTamanioMemoria(proceso.PID, tamanio) {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Tamaño del proceso: %d -> %d", proceso.PID, proceso.ProcessSize, tamanio))
+			proceso.ProcessSize = uint(tamanio)
+			cpu.continuarTrasSyscall(proceso, strconv.Itoa(tamanio))
+		} else {
+			// como un FORK que no pudo crear al hijo: el proceso sigue con su tamaño y recibe -1
+			clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - BRK rechazado, el proceso sigue con %d bytes", proceso.PID, proceso.ProcessSize))
+			cpu.continuarTrasSyscall(proceso, "-1")
+		}
 
 	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "DUMP_MEMORY" {
 		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: DUMP_MEMORY", proceso.PID))
 		go ManejarMemoryDump(proceso)
-		cpusLibres.Agregar(cpusOcupadas.SacarPorID(cpu.Identificador))
-		sem_cpusLibres <- 1
+		cpu.liberar(proceso)
+
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "PAGE_FAULT" {
+		pagina, err := -1, error(nil)
+		if len(respuesta.Valores) > NRO_PAGINA {
+			pagina, err = strconv.Atoi(respuesta.Valores[NRO_PAGINA])
+		}
+		if err != nil || pagina < 0 {
+			clientUtils.Logger.Error("Error al parsear la página del PAGE_FAULT")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		go ManejarPageFault(proceso, pagina)
+		cpu.liberar(proceso)
 
 	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "IO" {
 		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Solicitó syscall: IO", proceso.PID))
 		go manejarIo(respuesta, proceso)
-		cpusLibres.Agregar(cpusOcupadas.SacarPorID(cpu.Identificador))
-		sem_cpusLibres <- 1
+		cpu.liberar(proceso)
 	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "DESALOJO" {
-		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Desalojado por algoritmo SJF/SRT", proceso.PID))
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Desalojado por algoritmo %s", proceso.PID, globalskernel.KernelConfig.SchedulerAlgorithm))
+		go Plp.pcp.RecibirProceso(proceso)
+		cpu.sem_interrupcionAtendida <- true
+	} else if respuesta.Valores[MOTIVO_DEVOLUCION] == "FIN_QUANTUM" {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Desalojado por fin de Quantum", proceso.PID))
+		// el flag se toma antes de volver a READY, que también lo mira
+		desalojado := proceso.estaSiendoDesalojado.Swap(false)
 		go Plp.pcp.RecibirProceso(proceso)
-		cpu.sem_interrupcionAtendida <- struct{}{}
+		cpu.soltar(desalojado)
 	} else {
 		clientUtils.Logger.Error("Error, motivo de devolución de proceso desconocido")
 		http.Error(w, "Bad Request", http.StatusBadRequest)
@@ -1247,11 +2220,11 @@ func ManejarMemoryDump(proceso *PCB) {
 	Plp.pcp.EnviarProcesoABlocked(proceso)
 	respuesta := EnviarMemoryDump(proceso.PID)
 	proceso, ok := Plp.blockedState.BuscarYSacarPorPID(proceso.PID)
-	proceso.MT.blockedTime += proceso.timeInState()
 	if !ok {
 		clientUtils.Logger.Error("Error al encontrar el proceso en blocked")
 		return
 	}
+	proceso.MT.blockedTime += proceso.timeInState()
 	if respuesta {
 		clientUtils.Logger.Info(fmt.Sprintf("## (%d) Pasa del estado BLOCKED al estado READY", proceso.PID))
 		Plp.pcp.RecibirProceso(proceso)
@@ -1260,6 +2233,41 @@ func ManejarMemoryDump(proceso *PCB) {
 	}
 }
 
+func EnviarPedidoPagina(PID uint, pagina int) bool {
+	valores := []string{strconv.Itoa(int(PID)), strconv.Itoa(pagina)}
+	paquete := clientUtils.Paquete{Valores: valores}
+
+	ip := globalskernel.KernelConfig.IpMemory
+	puerto := globalskernel.KernelConfig.PortMemory
+	endpoint := "cargarPagina"
+
+	resp := clientUtils.EnviarPaqueteConRespuesta(ip, puerto, endpoint, paquete)
+	if resp != nil && resp.StatusCode == http.StatusOK {
+		return true
+	}
+
+	clientUtils.Logger.Warn(fmt.Sprintf("Error al cargar la página %d del proceso PID %d", pagina, PID))
+	return false
+}
+
+// El proceso queda en BLOCKED mientras Memoria trae la página. Si en el medio se suspendió,
+// desbloquearProceso lo pasa de SUSP_BLOCKED a SUSP_READY
+func ManejarPageFault(proceso *PCB, pagina int) {
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Bloqueado por PAGE_FAULT - Página: %d", proceso.PID, pagina))
+	Plp.pcp.EnviarProcesoABlocked(proceso)
+
+	if !EnviarPedidoPagina(proceso.PID, pagina) {
+		if err := FinalizarProcesoPorPID(proceso.PID); err != nil {
+			clientUtils.Logger.Error(fmt.Sprintf("## (%d) - No se pudo finalizar tras el PAGE_FAULT: %s", proceso.PID, err))
+		}
+		return
+	}
+
+	if !desbloquearProceso(proceso.PID) {
+		clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - Página cargada pero el proceso ya no estaba bloqueado", proceso.PID))
+	}
+}
+
 func manejarIo(respuesta serverUtils.Paquete, proceso *PCB) {
 	nombre := respuesta.Valores[NOMBRE_IO]
 	time, err := strconv.Atoi(respuesta.Valores[TIME])
@@ -1274,6 +2282,7 @@ func manejarIo(respuesta serverUtils.Paquete, proceso *PCB) {
 		ioDesocupada, ok := grupoIo.ObtenerIoLibre()
 		if !ok {
 			grupoIo.AgregarPedido(PedidoIo{PID: proceso.PID, time: time})
+			notificarBloqueo()
 		} else {
 			ioDesocupada.enviarProceso(proceso.PID, time)
 		}
@@ -1335,17 +2344,6 @@ func FinIos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var estaEnBlocked bool
-	proceso, estaEnSuspBlocked := Pmp.suspBlockedState.BuscarYSacarPorPID(uint(ioPid))
-	if !estaEnSuspBlocked {
-		proceso, estaEnBlocked = Plp.blockedState.BuscarYSacarPorPID(uint(ioPid))
-		if !estaEnBlocked {
-			clientUtils.Logger.Error("Error al encontrar el proceso en blocked")
-			http.Error(w, "Bad Request", http.StatusBadRequest)
-			return
-		}
-	}
-
 	ioOcupada, ok := iosRegistradas.BuscarIoPorGrupoYPID(nombre, ioPid)
 	if !ok {
 		clientUtils.Logger.Error("Error al buscar la IO por su PID")
@@ -1356,6 +2354,19 @@ func FinIos(w http.ResponseWriter, r *http.Request) {
 	ioOcupada.MarcarLibre()
 	ioOcupada.SetPIDEnEjecucion(-1)
 	go manejarPendientesIo(nombre)
+
+	var estaEnBlocked bool
+	proceso, estaEnSuspBlocked := Pmp.suspBlockedState.BuscarYSacarPorPID(uint(ioPid))
+	if !estaEnSuspBlocked {
+		proceso, estaEnBlocked = Plp.blockedState.BuscarYSacarPorPID(uint(ioPid))
+		if !estaEnBlocked {
+			// Pudo haber sido finalizado mientras usaba la IO, la instancia igual queda libre
+			clientUtils.Logger.Warn(fmt.Sprintf("## (%d) finalizó IO pero ya no estaba bloqueado", ioPid))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+
 	if estaEnBlocked {
 		clientUtils.Logger.Info(fmt.Sprintf("## (%d) finalizó IO y pasa a READY", proceso.PID))
 		proceso.MT.blockedTime += proceso.timeInState()
@@ -1396,15 +2407,16 @@ func DesconexionIos(w http.ResponseWriter, r *http.Request) {
 		if !estaEnSuspBlocked {
 			proceso, estaEnBlocked = Plp.blockedState.BuscarYSacarPorPID(uint(io.PIDEnEjecucion))
 			if !estaEnBlocked {
-				clientUtils.Logger.Error("Error al encontrar el proceso en blocked")
-				http.Error(w, "Bad Request", http.StatusBadRequest)
-				return
+				// Pudo haber sido finalizado mientras usaba la IO
+				clientUtils.Logger.Warn(fmt.Sprintf("## (%d) ya no estaba bloqueado al desconectarse la IO", io.PIDEnEjecucion))
 			}
 		}
 	}
 
 	manejarDesconexionIo(nombre, io)
-	Plp.FinalizarProceso(proceso)
+	if proceso != nil {
+		Plp.FinalizarProceso(proceso)
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
@@ -1451,7 +2463,7 @@ func finalizarTodosLosProcesosPendientes(grupoIo *GrupoIo) {
 			proceso, estaEnBlocked = Plp.blockedState.BuscarYSacarPorPID(PedidoIo.PID)
 			if !estaEnBlocked {
 				clientUtils.Logger.Error("Error al encontrar el proceso en blocked")
-				return
+				continue
 			}
 			proceso.MT.blockedTime += proceso.timeInState()
 		}
@@ -1459,24 +2471,1033 @@ func finalizarTodosLosProcesosPendientes(grupoIo *GrupoIo) {
 	}
 }
 
-func IniciarKernel(filePath string, processSize uint) {
-	fmt.Println("Presione ENTER para iniciar la planificación de Largo Plazo...")
-	<-iniciarLargoPlazo
-	IniciarProceso(filePath, processSize)
+// El proceso inicial queda en NEW hasta que se inicie la planificación de Largo Plazo
+func IniciarKernel(filePath string, processSize uint, prioridad int) {
+	fmt.Println("Presione ENTER o escriba INICIAR_PLANIFICACION para iniciar la planificación de Largo Plazo...")
+	IniciarProceso(filePath, processSize, prioridad)
+}
+
+func IniciarProceso(filePath string, processSize uint, prioridad int) {
+	Plp.RecibirNuevoProceso(nuevoPCB(filePath, processSize, prioridad, -1))
 }
 
-func IniciarProceso(filePath string, processSize uint) {
+// Crea el PCB con el próximo PID y lo registra en la tabla de procesos
+func nuevoPCB(filePath string, processSize uint, prioridad int, pidPadre int) *PCB {
 	muProximoPID.Lock()
-	nuevaPCB := PCB{PID: proximoPID, PC: 0, FilePath: filePath, ProcessSize: processSize, estimacion: float64(globalskernel.KernelConfig.InitialEstimate)}
+	nuevaPCB := &PCB{PID: proximoPID, PC: 0, FilePath: filePath, ProcessSize: processSize, estimacion: float64(globalskernel.KernelConfig.InitialEstimate), Prioridad: prioridad, prioridadBase: prioridad, PIDPadre: pidPadre}
 	proximoPID++
 	muProximoPID.Unlock()
+	nuevaPCB.cambiarNivel(0)
+
+	muProcesos.Lock()
+	tablaProcesos[nuevaPCB.PID] = nuevaPCB
+	muProcesos.Unlock()
+	return nuevaPCB
+}
+
+// FORK: el hijo es una copia del padre en el punto del FORK (mismo script, PC y registros) salvo por
+// el registro indicado, que queda en 0. Memoria le comparte las páginas del padre con copy-on-write
+// en el momento, así que entra a NEW con su memoria ya armada. Devuelve nil si Memoria lo rechazó
+func forkProceso(padre *PCB, registro string) *PCB {
+	hijo := nuevoPCB(padre.FilePath, padre.ProcessSize, padre.prioridadBase, int(padre.PID))
+	hijo.PC = padre.PC
+	hijo.Registros = padre.Registros
+	hijo.Registros.Asignar(registro, 0)
+	hijo.forkeado = true
+
+	if !EnviarForkMemoria(padre.PID, hijo.PID) {
+		muProcesos.Lock()
+		delete(tablaProcesos, hijo.PID)
+		muProcesos.Unlock()
+		return nil
+	}
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) - FORK creó al proceso %d", padre.PID, hijo.PID))
+	return hijo
+}
+
+func EnviarForkMemoria(pidPadre uint, pidHijo uint) bool {
+	valores := []string{strconv.Itoa(int(pidPadre)), strconv.Itoa(int(pidHijo))}
+	paquete := clientUtils.Paquete{Valores: valores}
+
+	ip := globalskernel.KernelConfig.IpMemory
+	puerto := globalskernel.KernelConfig.PortMemory
+	endpoint := "forkProceso"
+
+	resp := clientUtils.EnviarPaqueteConRespuesta(ip, puerto, endpoint, paquete)
+	if resp != nil && resp.StatusCode == http.StatusOK {
+		return true
+	}
+
+	if resp == nil {
+		clientUtils.Logger.Warn(fmt.Sprintf("Error de conexión al hacer FORK del proceso PID %d (respuesta nula)", pidPadre))
+	} else {
+		clientUtils.Logger.Warn(fmt.Sprintf("Memoria rechazó el FORK del proceso PID %d. Status: %s", pidPadre, resp.Status))
+	}
+	return false
+}
+
+//----------------------- Jerarquía de procesos -------------------------
+
+// Los procesos que no terminaron, para resolver padres e hijos sin importar en qué estado están
+var tablaProcesos = make(map[uint]*PCB)
+
+// Procesos bloqueados por WAIT_PID, indexados por el PID del hijo que esperan
+var esperasPorHijo = make(map[uint][]uint)
+var muProcesos sync.Mutex
+
+// Bloquea al proceso hasta que su hijo llegue a EXIT. Devuelve false si no tiene un hijo vivo con ese PID
+func (plp *PlanificadorLargoPlazo) esperarHijo(proceso *PCB, pidHijo uint) bool {
+	muProcesos.Lock()
+	defer muProcesos.Unlock()
+
+	hijo, ok := tablaProcesos[pidHijo]
+	if !ok || hijo.finalizado || hijo.PIDPadre != int(proceso.PID) {
+		return false
+	}
+
+	esperasPorHijo[pidHijo] = append(esperasPorHijo[pidHijo], proceso.PID)
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Bloqueado esperando al proceso %d", proceso.PID, pidHijo))
+	plp.bloquearPorSyscall(proceso, "WAIT_PID")
+	return true
+}
+
+// Manda a BLOCKED a un proceso que estaba en EXEC atendiendo una syscall sincrónica
+func (plp *PlanificadorLargoPlazo) bloquearPorSyscall(proceso *PCB, motivo string) {
+	proceso.detenerQuantum()
+	plp.pcp.schedulerEstrategy.registrarDevolucion(proceso, motivo)
+	plp.pcp.EnviarProcesoABlocked(proceso)
+	notificarBloqueo()
+}
+
+// Despierta a quienes esperaban al proceso y aplica la política de huérfanos a sus hijos.
+// Con los que esperaban ya despiertos nadie más lo necesita en la tabla y se saca
+func (plp *PlanificadorLargoPlazo) registrarFinalizacion(proceso *PCB) {
+	muProcesos.Lock()
+	proceso.finalizado = true
+	esperando := esperasPorHijo[proceso.PID]
+	delete(esperasPorHijo, proceso.PID)
+	delete(tablaProcesos, proceso.PID)
+
+	var huerfanos []*PCB
+	for _, hijo := range tablaProcesos {
+		if hijo.PIDPadre == int(proceso.PID) && !hijo.finalizado {
+			huerfanos = append(huerfanos, hijo)
+		}
+	}
+	cascada := globalskernel.KernelConfig.PoliticaHuerfanos == "CASCADA"
+	if !cascada {
+		// Los huérfanos pasan a ser hijos del proceso 0, salvo que el que termina sea el 0
+		nuevoPadre := 0
+		if proceso.PID == 0 {
+			nuevoPadre = -1
+		}
+		for _, hijo := range huerfanos {
+			hijo.PIDPadre = nuevoPadre
+		}
+	}
+	muProcesos.Unlock()
+
+	for _, pid := range esperando {
+		desbloquearProceso(pid)
+	}
+
+	for _, hijo := range huerfanos {
+		if cascada {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Finalizado por finalización de su padre %d", hijo.PID, proceso.PID))
+			if err := FinalizarProcesoPorPID(hijo.PID); err != nil {
+				clientUtils.Logger.Warn(err.Error())
+			}
+		} else {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Queda huérfano, su nuevo padre es %d", hijo.PID, hijo.PIDPadre))
+		}
+	}
+}
+
+// Lleva a READY (o a SUSP_READY si fue suspendido) a un proceso bloqueado por un evento del Kernel.
+// Lo saca de BLOCKED en el momento, pero el ingreso a READY puede esperar una CPU y se hace aparte
+func desbloquearProceso(pid uint) bool {
+	if proceso, ok := Pmp.suspBlockedState.BuscarYSacarPorPID(pid); ok {
+		proceso.MT.suspBlockedTime += proceso.timeInState()
+		go Pmp.EnviarProcesoASuspReady(proceso)
+		return true
+	}
+	if proceso, ok := Plp.blockedState.BuscarYSacarPorPID(pid); ok {
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) Pasa del estado BLOCKED al estado READY", proceso.PID))
+		proceso.MT.blockedTime += proceso.timeInState()
+		go Plp.pcp.RecibirProceso(proceso)
+		return true
+	}
+	return false
+}
+
+//----------------------- Recursos (semáforos y mutex) -------------------------
+
+type Recurso struct {
+	Nombre     string
+	Tipo       string
+	instancias int          // negativo: cantidad de procesos esperando
+	asignados  map[uint]int // instancias tomadas por cada PID
+	bloqueados []uint
+	mu         sync.Mutex
+}
+
+var recursos = make(map[string]*Recurso)
+
+func IniciarRecursos() {
+	for _, config := range globalskernel.KernelConfig.Recursos {
+		recurso := &Recurso{Nombre: config.Nombre, Tipo: config.Tipo, instancias: config.Instancias, asignados: make(map[uint]int)}
+		if recurso.Tipo == "" {
+			recurso.Tipo = "SEMAFORO"
+		}
+		if recurso.Tipo == "MUTEX" {
+			recurso.instancias = 1
+		}
+		recursos[recurso.Nombre] = recurso
+	}
+}
+
+// Pide a Memoria que deje al proceso con ese tamaño en bytes, agregando o sacando páginas del final
+func EnviarCambioTamanioMemoria(PID uint, tamanio int) bool {
+	valores := []string{strconv.Itoa(int(PID)), strconv.Itoa(tamanio)}
+	paquete := clientUtils.Paquete{Valores: valores}
+
+	ip := globalskernel.KernelConfig.IpMemory
+	puerto := globalskernel.KernelConfig.PortMemory
+	endpoint := "cambiarTamanio"
+
+	resp := clientUtils.EnviarPaqueteConRespuesta(ip, puerto, endpoint, paquete)
+	if resp != nil && resp.StatusCode == http.StatusOK {
+		return true
+	}
+
+	if resp == nil {
+		clientUtils.Logger.Warn(fmt.Sprintf("Error de conexión al cambiar el tamaño del proceso PID %d (respuesta nula)", PID))
+	} else {
+		motivo, _ := io.ReadAll(resp.Body)
+		clientUtils.Logger.Error(fmt.Sprintf("Memoria no pudo llevar el proceso PID %d a %d bytes: %s", PID, tamanio, strings.TrimSpace(string(motivo))))
+	}
+	return false
+}
+
+func esSyscallDeMemoriaCompartida(motivo string) bool {
+	switch motivo {
+	case "SHM_CREATE", "SHM_ATTACH", "SHM_DETACH":
+		return true
+	}
+	return false
+}
+
+// Reenvía a Memoria una syscall de memoria compartida con el PID delante de sus parámetros
+func EnviarSyscallMemoriaCompartida(PID uint, motivo string, parametros []string) bool {
+	endpoints := map[string]string{
+		"SHM_CREATE": "shmCrear",
+		"SHM_ATTACH": "shmAdjuntar",
+		"SHM_DETACH": "shmDesadjuntar",
+	}
+	valores := append([]string{strconv.Itoa(int(PID))}, parametros...)
+	paquete := clientUtils.Paquete{Valores: valores}
+
+	ip := globalskernel.KernelConfig.IpMemory
+	puerto := globalskernel.KernelConfig.PortMemory
+
+	resp := clientUtils.EnviarPaqueteConRespuesta(ip, puerto, endpoints[motivo], paquete)
+	if resp != nil && resp.StatusCode == http.StatusOK {
+		return true
+	}
+
+	if resp == nil {
+		clientUtils.Logger.Warn(fmt.Sprintf("Error de conexión en %s del proceso PID %d (respuesta nula)", motivo, PID))
+	} else {
+		clientUtils.Logger.Warn(fmt.Sprintf("Memoria rechazó %s del proceso PID %d. Status: %s", motivo, PID, resp.Status))
+	}
+	return false
+}
+
+func esSyscallDeRecurso(motivo string) bool {
+	switch motivo {
+	case "WAIT", "SIGNAL", "MUTEX_LOCK", "MUTEX_UNLOCK":
+		return true
+	}
+	return false
+}
+
+// Atiende WAIT/SIGNAL/MUTEX_LOCK/MUTEX_UNLOCK. Devuelve SYSCALL_CONTINUAR, SYSCALL_BLOQUEADO
+// si el proceso quedó esperando el recurso, o "" si el pedido es inválido y hay que finalizarlo
+func atenderSyscallDeRecurso(proceso *PCB, motivo string, nombre string) string {
+	recurso, ok := recursos[nombre]
+	if !ok {
+		clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - %s sobre recurso inexistente: %s", proceso.PID, motivo, nombre))
+		return ""
+	}
+	esMutex := motivo == "MUTEX_LOCK" || motivo == "MUTEX_UNLOCK"
+	if esMutex != (recurso.Tipo == "MUTEX") {
+		clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - %s no se puede usar sobre el %s %s", proceso.PID, motivo, recurso.Tipo, nombre))
+		return ""
+	}
+
+	if motivo == "WAIT" || motivo == "MUTEX_LOCK" {
+		if recurso.tomar(proceso, motivo) {
+			return SYSCALL_CONTINUAR
+		}
+		return SYSCALL_BLOQUEADO
+	}
+
+	if !recurso.devolver(proceso.PID, esMutex) {
+		clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - MUTEX_UNLOCK de %s sin ser su dueño", proceso.PID, nombre))
+		return ""
+	}
+	return SYSCALL_CONTINUAR
+}
+
+// Devuelve true si el proceso obtuvo una instancia. Si no hay, queda bloqueado en la cola del recurso
+func (r *Recurso) tomar(proceso *PCB, motivo string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.Tipo == "MUTEX" && r.asignados[proceso.PID] > 0 {
+		// El dueño vuelve a pedir el mutex: no se bloquea contra sí mismo
+		clientUtils.Logger.Warn(fmt.Sprintf("## (%d) - ya tiene tomado el mutex %s", proceso.PID, r.Nombre))
+		return true
+	}
+
+	r.instancias--
+	if r.instancias >= 0 {
+		r.asignados[proceso.PID]++
+		return true
+	}
+
+	r.bloqueados = append(r.bloqueados, proceso.PID)
+	proceso.ME.recursoCount++
+	proceso.inicioEsperaRecurso = time.Now()
+	clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Bloqueado por recurso: %s", proceso.PID, r.Nombre))
+	// se bloquea con el lock tomado para que un SIGNAL no lo busque antes de que esté en BLOCKED
+	Plp.bloquearPorSyscall(proceso, motivo)
+	return false
+}
+
+// Devuelve una instancia y, si había procesos esperando, se la asigna al primero y lo desbloquea.
+// Un mutex solo lo puede devolver su dueño
+func (r *Recurso) devolver(pid uint, soloDuenio bool) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.asignados[pid] > 0 {
+		r.asignados[pid]--
+		if r.asignados[pid] == 0 {
+			delete(r.asignados, pid)
+		}
+	} else if soloDuenio {
+		return false
+	}
+
+	r.instancias++
+	if r.instancias <= 0 && len(r.bloqueados) > 0 {
+		proximo := r.bloqueados[0]
+		r.bloqueados = r.bloqueados[1:]
+		r.asignados[proximo]++
+		r.registrarFinDeEspera(proximo)
+		desbloquearProceso(proximo)
+	}
+	return true
+}
+
+func (r *Recurso) registrarFinDeEspera(pid uint) {
+	muProcesos.Lock()
+	proceso, ok := tablaProcesos[pid]
+	muProcesos.Unlock()
+	if ok && !proceso.inicioEsperaRecurso.IsZero() {
+		proceso.MT.recursoTime += float64(time.Since(proceso.inicioEsperaRecurso).Microseconds()) / 1000.0
+		proceso.inicioEsperaRecurso = time.Time{}
+	}
+}
+
+// Al finalizar, el proceso sale de las colas de espera y devuelve todo lo que tenía tomado
+func liberarRecursos(proceso *PCB) {
+	for _, recurso := range recursos {
+		recurso.mu.Lock()
+		for i, pid := range recurso.bloqueados {
+			if pid == proceso.PID {
+				recurso.bloqueados = append(recurso.bloqueados[:i], recurso.bloqueados[i+1:]...)
+				recurso.instancias++
+				recurso.registrarFinDeEspera(pid)
+				break
+			}
+		}
+		tomadas := recurso.asignados[proceso.PID]
+		recurso.mu.Unlock()
+
+		for ; tomadas > 0; tomadas-- {
+			clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Libera el recurso %s al finalizar", proceso.PID, recurso.Nombre))
+			recurso.devolver(proceso.PID, false)
+		}
+	}
+}
+
+//----------------------- Detección de deadlock -------------------------
 
-	Plp.RecibirNuevoProceso(&nuevaPCB)
+// Arista del grafo de espera: el proceso Desde espera a que Hasta libere algo
+type AristaEspera struct {
+	Desde  uint   `json:"desde"`
+	Hasta  uint   `json:"hasta"`
+	Motivo string `json:"motivo"`
 }
 
-func EsperarEnter() {
-	for {
-		bufio.NewReader(os.Stdin).ReadString('\n')
-		iniciarLargoPlazo <- struct{}{}
+type GrafoEspera struct {
+	Aristas []AristaEspera `json:"aristas"`
+	Ciclos  [][]uint       `json:"ciclos"`
+}
+
+var muDeteccion sync.Mutex
+
+func IniciarDeteccionDeadlock() {
+	if globalskernel.KernelConfig.DeteccionDeadlock != "PERIODICA" {
+		return
+	}
+	intervalo := time.Duration(globalskernel.KernelConfig.IntervaloDeteccion) * time.Millisecond
+	if intervalo <= 0 {
+		clientUtils.Logger.Error("intervalo_deteccion inválido, no se detectan deadlocks periódicamente")
+		return
+	}
+	go func() {
+		for range time.Tick(intervalo) {
+			detectarDeadlocks()
+		}
+	}()
+}
+
+// Se llama cada vez que un proceso queda esperando a otro
+func notificarBloqueo() {
+	if globalskernel.KernelConfig.DeteccionDeadlock == "AL_BLOQUEAR" {
+		go detectarDeadlocks()
+	}
+}
+
+// Arma el grafo con la posesión de recursos, las colas de IO y los WAIT_PID. Un ciclo que pasa por
+// un semáforo de varias instancias no alcanza para que haya deadlock, así que los ciclos se buscan
+// solo entre los procesos que el algoritmo de detección deja sin poder terminar
+func construirGrafoEspera() GrafoEspera {
+	aristas := []AristaEspera{}
+	estado := estadoDeEspera{
+		disponibles: make(map[string]int),
+		asignados:   make(map[uint]map[string]int),
+		pedidos:     make(map[uint][]string),
+		depende:     make(map[uint][]uint),
+	}
+
+	nombres := make([]string, 0, len(recursos))
+	for nombre := range recursos {
+		nombres = append(nombres, nombre)
+	}
+	sort.Strings(nombres)
+	for _, nombre := range nombres {
+		recurso := recursos[nombre]
+		recurso.mu.Lock()
+		estado.disponibles[nombre] = max(recurso.instancias, 0)
+		for duenio, tomadas := range recurso.asignados {
+			if estado.asignados[duenio] == nil {
+				estado.asignados[duenio] = make(map[string]int)
+			}
+			estado.asignados[duenio][nombre] += tomadas
+		}
+		for _, esperando := range recurso.bloqueados {
+			estado.pedidos[esperando] = append(estado.pedidos[esperando], nombre)
+			for duenio := range recurso.asignados {
+				aristas = append(aristas, AristaEspera{Desde: esperando, Hasta: duenio, Motivo: "RECURSO " + nombre})
+			}
+		}
+		recurso.mu.Unlock()
+	}
+
+	for _, grupo := range iosRegistradas.Grupos() {
+		esperando, usando := grupo.EstadoDeUso()
+		for _, pid := range esperando {
+			estado.depende[pid] = append(estado.depende[pid], usando...)
+			for _, usuario := range usando {
+				aristas = append(aristas, AristaEspera{Desde: pid, Hasta: usuario, Motivo: "IO " + grupo.Nombre})
+			}
+		}
+	}
+
+	muProcesos.Lock()
+	for hijo, padres := range esperasPorHijo {
+		for _, padre := range padres {
+			estado.depende[padre] = append(estado.depende[padre], hijo)
+			aristas = append(aristas, AristaEspera{Desde: padre, Hasta: hijo, Motivo: "WAIT_PID"})
+		}
+	}
+	muProcesos.Unlock()
+
+	sort.Slice(aristas, func(i, j int) bool {
+		if aristas[i].Desde != aristas[j].Desde {
+			return aristas[i].Desde < aristas[j].Desde
+		}
+		return aristas[i].Hasta < aristas[j].Hasta
+	})
+
+	enDeadlock := estado.procesosEnDeadlock()
+	var aristasEnDeadlock []AristaEspera
+	for _, arista := range aristas {
+		if enDeadlock[arista.Desde] && enDeadlock[arista.Hasta] {
+			aristasEnDeadlock = append(aristasEnDeadlock, arista)
+		}
+	}
+	return GrafoEspera{Aristas: aristas, Ciclos: buscarCiclos(aristasEnDeadlock)}
+}
+
+// Foto de lo que tiene y espera cada proceso para el algoritmo de detección
+type estadoDeEspera struct {
+	disponibles map[string]int          // instancias libres de cada recurso
+	asignados   map[uint]map[string]int // instancias tomadas por cada proceso
+	pedidos     map[uint][]string       // recursos por los que está bloqueado, una instancia de cada uno
+	depende     map[uint][]uint         // IO o WAIT_PID: sigue en cuanto termine alguno de estos procesos
+}
+
+// Algoritmo de detección con varias instancias: se suponen terminados los procesos que no esperan
+// nada y se devuelve lo que tenían, después cualquiera cuyo pedido alcance con lo disponible, hasta
+// que no cambie nada. Los que quedan no pueden terminar nunca
+func (e estadoDeEspera) procesosEnDeadlock() map[uint]bool {
+	bloqueados := make(map[uint]bool)
+	for pid := range e.pedidos {
+		bloqueados[pid] = true
+	}
+	for pid := range e.depende {
+		bloqueados[pid] = true
+	}
+
+	trabajo := make(map[string]int)
+	for nombre, libres := range e.disponibles {
+		trabajo[nombre] = libres
+	}
+	devolver := func(pid uint) {
+		for nombre, tomadas := range e.asignados[pid] {
+			trabajo[nombre] += tomadas
+		}
+	}
+	for pid := range e.asignados {
+		if !bloqueados[pid] {
+			devolver(pid)
+		}
+	}
+
+	puedeSeguir := func(pid uint) bool {
+		pedidas := make(map[string]int)
+		for _, nombre := range e.pedidos[pid] {
+			pedidas[nombre]++
+		}
+		for nombre, cantidad := range pedidas {
+			if trabajo[nombre] < cantidad {
+				return false
+			}
+		}
+		if len(e.depende[pid]) == 0 {
+			return true
+		}
+		for _, otro := range e.depende[pid] {
+			if !bloqueados[otro] {
+				return true
+			}
+		}
+		return false
+	}
+
+	for cambio := true; cambio; {
+		cambio = false
+		for pid := range bloqueados {
+			if puedeSeguir(pid) {
+				delete(bloqueados, pid)
+				devolver(pid)
+				cambio = true
+			}
+		}
+	}
+	return bloqueados
+}
+
+// DFS sobre el grafo de espera, devuelve un ciclo por cada componente que tenga alguno
+func buscarCiclos(aristas []AristaEspera) [][]uint {
+	adyacentes := make(map[uint][]uint)
+	var nodos []uint
+	for _, arista := range aristas {
+		if _, ok := adyacentes[arista.Desde]; !ok {
+			nodos = append(nodos, arista.Desde)
+		}
+		adyacentes[arista.Desde] = append(adyacentes[arista.Desde], arista.Hasta)
+	}
+
+	const (
+		sinVisitar = iota
+		enCamino
+		terminado
+	)
+	estado := make(map[uint]int)
+	var camino []uint
+	ciclos := [][]uint{}
+
+	var visitar func(pid uint)
+	visitar = func(pid uint) {
+		estado[pid] = enCamino
+		camino = append(camino, pid)
+		for _, siguiente := range adyacentes[pid] {
+			switch estado[siguiente] {
+			case sinVisitar:
+				visitar(siguiente)
+			case enCamino:
+				// el ciclo es el tramo del camino desde la primera aparición de siguiente
+				for i := len(camino) - 1; i >= 0; i-- {
+					if camino[i] == siguiente {
+						ciclos = append(ciclos, append([]uint{}, camino[i:]...))
+						break
+					}
+				}
+			}
+		}
+		camino = camino[:len(camino)-1]
+		estado[pid] = terminado
+	}
+
+	for _, pid := range nodos {
+		if estado[pid] == sinVisitar {
+			visitar(pid)
+		}
+	}
+	return ciclos
+}
+
+func detectarDeadlocks() {
+	muDeteccion.Lock()
+	defer muDeteccion.Unlock()
+
+	finalizados := make(map[uint]bool)
+	for _, ciclo := range construirGrafoEspera().Ciclos {
+		resuelto := false
+		for _, pid := range ciclo {
+			resuelto = resuelto || finalizados[pid]
+		}
+		if resuelto {
+			continue
+		}
+
+		pids := make([]string, len(ciclo))
+		for i, pid := range ciclo {
+			pids[i] = strconv.Itoa(int(pid))
+		}
+		clientUtils.Logger.Warn(fmt.Sprintf("## Deadlock detectado entre los procesos: %s", strings.Join(pids, ", ")))
+
+		victima, ok := elegirVictima(ciclo)
+		if !ok {
+			continue
+		}
+		clientUtils.Logger.Info(fmt.Sprintf("## (%d) - Finalizado para resolver deadlock (%s)", victima, globalskernel.KernelConfig.VictimaDeadlock))
+		finalizados[victima] = true
+		if err := FinalizarProcesoPorPID(victima); err != nil {
+			clientUtils.Logger.Warn(err.Error())
+		}
+	}
+}
+
+// Elige el proceso a finalizar según victima_deadlock. Sin política configurada solo se loguea
+func elegirVictima(ciclo []uint) (uint, bool) {
+	var esMejorVictima func(candidato, victima *PCB) bool
+	switch globalskernel.KernelConfig.VictimaDeadlock {
+	case "MAS_JOVEN":
+		esMejorVictima = func(candidato, victima *PCB) bool { return candidato.PID > victima.PID }
+	case "MENOR_TAMANIO":
+		esMejorVictima = func(candidato, victima *PCB) bool { return candidato.ProcessSize < victima.ProcessSize }
+	case "MENOR_PRIORIDAD":
+		esMejorVictima = func(candidato, victima *PCB) bool { return candidato.Prioridad > victima.Prioridad }
+	default:
+		return 0, false
+	}
+
+	muProcesos.Lock()
+	defer muProcesos.Unlock()
+
+	var victima *PCB
+	for _, pid := range ciclo {
+		if proceso, ok := tablaProcesos[pid]; ok && (victima == nil || esMejorVictima(proceso, victima)) {
+			victima = proceso
+		}
+	}
+	if victima == nil {
+		return 0, false
+	}
+	return victima.PID, true
+}
+
+// GrafoDeEspera devuelve el grafo actual en JSON, o en DOT con ?formato=dot
+func GrafoDeEspera(w http.ResponseWriter, r *http.Request) {
+	grafo := construirGrafoEspera()
+
+	if r.URL.Query().Get("formato") == "dot" {
+		// los procesos en deadlock se marcan en rojo
+		var dot strings.Builder
+		dot.WriteString("digraph espera {\n")
+		for _, ciclo := range grafo.Ciclos {
+			for _, pid := range ciclo {
+				fmt.Fprintf(&dot, "  \"%d\" [color=red];\n", pid)
+			}
+		}
+		for _, arista := range grafo.Aristas {
+			fmt.Fprintf(&dot, "  \"%d\" -> \"%d\" [label=\"%s\"];\n", arista.Desde, arista.Hasta, arista.Motivo)
+		}
+		dot.WriteString("}\n")
+
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(dot.String()))
+		return
+	}
+
+	respuesta, err := json.Marshal(grafo)
+	if err != nil {
+		clientUtils.Logger.Error("Error al codificar el grafo de espera", "error", err)
+		http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respuesta)
+}
+
+//----------------------------- Consola del Kernel -----------------------------
+
+// IniciarConsola lee comandos de stdin mientras corre la simulación.
+// Una línea vacía (ENTER) equivale a INICIAR_PLANIFICACION
+func IniciarConsola() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		ejecutarComando(strings.Fields(scanner.Text()))
+	}
+}
+
+func ejecutarComando(campos []string) {
+	if len(campos) == 0 {
+		Plp.Reanudar()
+		return
+	}
+
+	switch strings.ToUpper(campos[0]) {
+	case "INICIAR_PROCESO":
+		if len(campos) < 3 {
+			fmt.Println("Uso: INICIAR_PROCESO <path> <tamaño> [prioridad]")
+			return
+		}
+		tamProc, err := strconv.Atoi(campos[2])
+		if err != nil || tamProc < 0 {
+			fmt.Println("Tamaño inválido:", campos[2])
+			return
+		}
+		prioridad := 0
+		if len(campos) > 3 {
+			prioridad, err = strconv.Atoi(campos[3])
+			if err != nil {
+				fmt.Println("Prioridad inválida:", campos[3])
+				return
+			}
+		}
+		go IniciarProceso(campos[1], uint(tamProc), prioridad)
+
+	case "FINALIZAR_PROCESO":
+		if len(campos) < 2 {
+			fmt.Println("Uso: FINALIZAR_PROCESO <pid>")
+			return
+		}
+		pid, err := strconv.Atoi(campos[1])
+		if err != nil || pid < 0 {
+			fmt.Println("PID inválido:", campos[1])
+			return
+		}
+		if err := FinalizarProcesoPorPID(uint(pid)); err != nil {
+			fmt.Println(err)
+		}
+
+	case "LISTAR_PROCESOS":
+		for _, estado := range listasPorEstado() {
+			pids := []string{}
+			for _, proceso := range estado.lista.Listar() {
+				pids = append(pids, strconv.Itoa(int(proceso.PID)))
+			}
+			fmt.Printf("%-12s [%s]\n", estado.nombre, strings.Join(pids, ", "))
+		}
+
+	case "DETENER_PLANIFICACION":
+		Plp.Detener()
+
+	case "INICIAR_PLANIFICACION":
+		Plp.Reanudar()
+
+	case "MULTIPROGRAMACION":
+		if len(campos) < 2 {
+			fmt.Println("Uso: MULTIPROGRAMACION <grado> (0 = sin límite)")
+			return
+		}
+		grado, err := strconv.Atoi(campos[1])
+		if err != nil || grado < 0 {
+			fmt.Println("Grado inválido:", campos[1])
+			return
+		}
+		go CambiarGradoMultiprogramacion(grado)
+
+	default:
+		fmt.Println("Comando desconocido:", campos[0])
+	}
+}
+
+//----------------------- Endpoints de administración -------------------------
+
+type ProcesoAdmin struct {
+	PID            uint                `json:"pid"`
+	PIDPadre       int                 `json:"pid_padre"`
+	PC             uint                `json:"pc"`
+	Tamanio        uint                `json:"tamanio"`
+	Archivo        string              `json:"archivo"`
+	Estado         string              `json:"estado"`
+	Estimacion     float64             `json:"estimacion"`
+	Prioridad      int                 `json:"prioridad"`
+	Registros      registros.Registros `json:"registros"`
+	MetricasEstado map[string]uint     `json:"metricas_estado"`
+	MetricasTiempo map[string]float64  `json:"metricas_tiempo"`
+	Motivo         string              `json:"motivo_finalizacion,omitempty"`
+}
+
+type listaDeEstado struct {
+	nombre string
+	lista  *PCBList
+}
+
+func listasPorEstado() []listaDeEstado {
+	return []listaDeEstado{
+		{"NEW", &Plp.newState},
+		{"READY", &Plp.pcp.readyState},
+		{"EXEC", &Plp.pcp.execState},
+		{"BLOCKED", &Plp.blockedState},
+		{"SUSP_READY", &Pmp.suspReadyState},
+		{"SUSP_BLOCKED", &Pmp.suspBlockedState},
+		{"EXIT", &Plp.exitState},
+	}
+}
+
+func nuevoProcesoAdmin(proceso *PCB, estado string) ProcesoAdmin {
+	return ProcesoAdmin{
+		PID:        proceso.PID,
+		PIDPadre:   proceso.PIDPadre,
+		PC:         proceso.PC,
+		Tamanio:    proceso.ProcessSize,
+		Archivo:    proceso.FilePath,
+		Estado:     estado,
+		Estimacion: proceso.estimacion,
+		Prioridad:  proceso.Prioridad,
+		Registros:  proceso.Registros,
+		Motivo:     proceso.motivoFinalizacion,
+		MetricasEstado: map[string]uint{
+			"NEW":          proceso.ME.newCount,
+			"READY":        proceso.ME.readyCount,
+			"EXEC":         proceso.ME.execCount,
+			"BLOCKED":      proceso.ME.blockedCount,
+			"RECURSO":      proceso.ME.recursoCount,
+			"SUSP_READY":   proceso.ME.suspReadyCount,
+			"SUSP_BLOCKED": proceso.ME.suspBlockedCount,
+			"EXIT":         proceso.ME.exitCount,
+		},
+		MetricasTiempo: map[string]float64{
+			"NEW":          proceso.MT.newTime,
+			"READY":        proceso.MT.readyTime,
+			"EXEC":         proceso.MT.execTime,
+			"BLOCKED":      proceso.MT.blockedTime,
+			"RECURSO":      proceso.MT.recursoTime,
+			"SUSP_READY":   proceso.MT.suspReadyTime,
+			"SUSP_BLOCKED": proceso.MT.suspBlockedTime,
+			"EXIT":         proceso.MT.exitTime,
+		},
+	}
+}
+
+// ListarProcesos devuelve los procesos agrupados por estado, o solo los de ?estado=X
+func ListarProcesos(w http.ResponseWriter, r *http.Request) {
+	filtro := r.URL.Query().Get("estado")
+
+	procesos := make(map[string][]ProcesoAdmin)
+	for _, estado := range listasPorEstado() {
+		if filtro != "" && filtro != estado.nombre {
+			continue
+		}
+		procesos[estado.nombre] = []ProcesoAdmin{}
+		for _, proceso := range estado.lista.Listar() {
+			procesos[estado.nombre] = append(procesos[estado.nombre], nuevoProcesoAdmin(proceso, estado.nombre))
+		}
+	}
+
+	if len(procesos) == 0 {
+		http.Error(w, "Estado inexistente", http.StatusBadRequest)
+		return
+	}
+
+	respuesta, err := json.Marshal(procesos)
+	if err != nil {
+		clientUtils.Logger.Error("Error al codificar la lista de procesos", "error", err)
+		http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(respuesta)
+}
+
+// CrearProceso espera recibir ["archivo", "tamaño", "prioridad" (opcional)]
+func CrearProceso(w http.ResponseWriter, r *http.Request) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	tamProc, err := strconv.Atoi(paquete.Valores[1])
+	if err != nil || tamProc < 0 {
+		http.Error(w, "Tamaño inválido", http.StatusBadRequest)
+		return
+	}
+	prioridad := 0
+	if len(paquete.Valores) > 2 {
+		prioridad, err = strconv.Atoi(paquete.Valores[2])
+		if err != nil {
+			http.Error(w, "Prioridad inválida", http.StatusBadRequest)
+			return
+		}
+	}
+
+	go IniciarProceso(paquete.Valores[0], uint(tamProc), prioridad)
+	w.WriteHeader(http.StatusOK)
+}
+
+// EliminarProceso espera recibir ["pid"] y finaliza el proceso en el estado en que esté
+func EliminarProceso(w http.ResponseWriter, r *http.Request) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 1 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	pid, err := strconv.Atoi(paquete.Valores[0])
+	if err != nil || pid < 0 {
+		http.Error(w, "PID inválido", http.StatusBadRequest)
+		return
+	}
+
+	if err := FinalizarProcesoPorPID(uint(pid)); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// CambiarMultiprogramacion espera recibir ["grado"], 0 para no tener límite
+func CambiarMultiprogramacion(w http.ResponseWriter, r *http.Request) {
+	paquete := serverUtils.RecibirPaquetes(w, r)
+	if len(paquete.Valores) < 1 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	grado, err := strconv.Atoi(paquete.Valores[0])
+	if err != nil || grado < 0 {
+		http.Error(w, "Grado inválido", http.StatusBadRequest)
+		return
+	}
+	go CambiarGradoMultiprogramacion(grado)
+	w.WriteHeader(http.StatusOK)
+}
+
+func DetenerPlanificacion(w http.ResponseWriter, r *http.Request) {
+	Plp.Detener()
+	w.WriteHeader(http.StatusOK)
+}
+
+func ReanudarPlanificacion(w http.ResponseWriter, r *http.Request) {
+	Plp.Reanudar()
+	w.WriteHeader(http.StatusOK)
+}
+
+// Reintentos mientras el proceso pasa de una lista de estado a otra y no está en ninguna
+const (
+	intentosBusquedaProceso = 50
+	esperaBusquedaProceso   = 10 * time.Millisecond
+)
+
+// Busca el proceso en todas las listas y lo lleva a EXIT. Si está en EXEC se interrumpe
+// a su CPU y la finalización se completa cuando la CPU lo devuelve. La tabla de procesos dice
+// si existe, así uno que está cambiando de lista se vuelve a buscar en vez de darlo por inexistente
+func FinalizarProcesoPorPID(pid uint) error {
+	muProcesos.Lock()
+	proceso, existe := tablaProcesos[pid]
+	finalizado := existe && proceso.finalizado
+	muProcesos.Unlock()
+	if finalizado {
+		return fmt.Errorf("el proceso %d ya finalizó", pid)
+	}
+
+	for intento := 0; existe && intento < intentosBusquedaProceso; intento++ {
+		if encontrado, err := finalizarSegunEstado(pid); encontrado {
+			return err
+		}
+		time.Sleep(esperaBusquedaProceso)
+	}
+
+	if _, ok := Plp.exitState.BuscarPorPID(pid); ok || finalizado {
+		return fmt.Errorf("el proceso %d ya finalizó", pid)
+	}
+	if existe {
+		return fmt.Errorf("el proceso %d está cambiando de estado, intente de nuevo", pid)
+	}
+	return fmt.Errorf("no existe el proceso %d", pid)
+}
+
+// Saca al proceso de la lista de su estado y lo finaliza. Devuelve false si no estaba en ninguna
+func finalizarSegunEstado(pid uint) (bool, error) {
+	if proceso, ok := Plp.newState.BuscarYSacarPorPID(pid); ok {
+		Plp.finalizarProcesoNuevo(proceso)
+		return true, nil
+	}
+
+	if proceso, ok := Plp.pcp.readyState.BuscarYSacarPorPID(pid); ok {
+		proceso.MT.readyTime += proceso.timeInState()
+		esperasSinProceso.Add(1)
+		go Plp.finalizarProcesoDesde(proceso, "READY")
+		return true, nil
+	}
+
+	if proceso, ok := Plp.blockedState.BuscarYSacarPorPID(pid); ok {
+		proceso.MT.blockedTime += proceso.timeInState()
+		iosRegistradas.CancelarPedidos(pid)
+		go Plp.finalizarProcesoDesde(proceso, "BLOCKED")
+		return true, nil
+	}
+
+	if proceso, ok := Pmp.suspBlockedState.BuscarYSacarPorPID(pid); ok {
+		proceso.MT.suspBlockedTime += proceso.timeInState()
+		iosRegistradas.CancelarPedidos(pid)
+		go Plp.finalizarProcesoDesde(proceso, "SUSP_BLOCKED")
+		return true, nil
+	}
+
+	if proceso, ok := Pmp.suspReadyState.BuscarYSacarPorPID(pid); ok {
+		proceso.MT.suspReadyTime += proceso.timeInState()
+		go Plp.finalizarProcesoDesde(proceso, "SUSP_READY")
+		return true, nil
+	}
+
+	if proceso, ok := Plp.pcp.execState.BuscarPorPID(pid); ok {
+		// Recién despachado o recién devuelto, la CPU todavía no figura
+		cpu, ok := cpusOcupadas.BuscarPorPIDEnEjecucion(pid)
+		if !ok {
+			return false, nil
+		}
+		proceso.finalizacionPedida.Store(true)
+		go cpu.enviarInterrupcion("FINALIZACION", pid)
+		return true, nil
+	}
+
+	if _, ok := Plp.exitState.BuscarPorPID(pid); ok {
+		return true, fmt.Errorf("el proceso %d ya finalizó", pid)
 	}
+	return false, nil
 }
@@ -2,6 +2,7 @@ package globalsmemoria
 
 import (
 	"sync"
+	"time"
 )
 
 type Proceso struct {
@@ -11,6 +12,12 @@ type Proceso struct {
 	TablaPaginasGlobal TablaPaginas
 	Size               int
 	Metricas           MetricasProceso
+	// Paginación por demanda: el proceso tiene una cantidad fija de marcos y reemplaza entre sus páginas
+	Marcos         []int     // marcos reservados para el proceso
+	Residentes     []*Pagina // página cargada en cada marco reservado, en el mismo orden
+	PunteroVictima int       // posición en Residentes desde donde arrancan FIFO y CLOCK
+	Suspendido     bool
+	MutexPaginas   sync.Mutex
 }
 
 type Config struct {
@@ -26,6 +33,14 @@ type Config struct {
 	LogLevel       string `json:"log_level"`
 	DumpPath       string `json:"dump_path"`
 	ScriptsPath    string `json:"scripts_path"`
+	// Validación de los scripts al iniciar un proceso
+	ValidarScripts bool     `json:"validar_scripts"`
+	DispositivosIO []string `json:"dispositivos_io"` // vacío = no se controlan los nombres de IO
+	// Paginación por demanda
+	PaginacionPorDemanda bool   `json:"paginacion_por_demanda"`
+	AlgoritmoReemplazo   string `json:"algoritmo_reemplazo"` // CLOCK, CLOCK-M, LRU o FIFO
+	MarcosPorProceso     int    `json:"marcos_por_proceso"`  // 0 = un marco por cada página del proceso
+	SwapMaxSize          int    `json:"swap_max_size"`       // bytes, 0 = sin límite
 }
 
 var MemoriaConfig *Config
@@ -64,6 +79,14 @@ type Pagina struct {
 		Lectura   bool
 	}
 	MutexPagina sync.Mutex
+	// Paginación por demanda
+	Numero    int
+	UltimoUso time.Time
+	SlotSwap  int // slot con el contenido vigente, -1 si nunca se bajó a swap (la página está en ceros)
+	// FORK: el marco se comparte con otro proceso y está sin permiso de escritura hasta que alguno escriba
+	CopiaEnEscritura bool
+	// Clave del segmento de memoria compartida al que pertenece el marco, "" si es una página propia
+	Segmento string
 }
 
 func NewPagina(marco int, presencia bool, escritura bool, lectura bool) Pagina {
@@ -80,6 +103,7 @@ func NewPagina(marco int, presencia bool, escritura bool, lectura bool) Pagina {
 			Lectura:   lectura,
 		},
 		MutexPagina: sync.Mutex{},
+		SlotSwap:    -1,
 	}
 }
 
@@ -109,19 +133,51 @@ var MemoriaUsuario []byte
 
 var BitmapMarcosLibres []bool
 
+// Segmento de memoria compartida (SHM_CREATE). Sus marcos son del segmento, no de los procesos:
+// se liberan cuando se desadjunta el último proceso
+type SegmentoCompartido struct {
+	Clave    string
+	Size     int
+	Marcos   []int
+	Procesos map[int]int // PID -> primera página lógica donde lo tiene adjunto
+	Usado    bool        // ya se adjuntó alguna vez, así que quedarse sin procesos lo libera
+	// PIDs que lo crearon antes de que nadie lo adjuntara. Si terminan todos sin adjuntarlo se libera
+	Creadores map[int]bool
+}
+
+var SegmentosCompartidos = make(map[string]*SegmentoCompartido) // clave -> segmento
+var MutexSegmentos sync.Mutex
+
+// Marco -> cantidad de páginas que lo usan, solo para los marcos compartidos por un FORK.
+// Se protege con MutexBitmapMarcosLibres
+var MarcosCompartidos = make(map[int]int)
+
 var ProcesosEnMemoria []*Proceso
 
+// CPU con la TLB etiquetada por PID: conserva traducciones entre procesos, así que hay que avisarle
+// cuando una página deja de estar en su marco
+type Cpu struct {
+	Identificador string
+	Ip            string
+	Puerto        int
+}
+
+var Cpus []Cpu
+var MutexCpus sync.Mutex
+
 type ProcesoEnSwap struct {
-	Pid    int
-	Size   int
-	Offset int64
+	Pid   int
+	Size  int
+	Slots []int // slot de swap de cada página, en orden
 }
 
 var TablaSwap = make(map[int]ProcesoEnSwap) // PID -> lista de procesos swap-eados
 
 var MutexTablaSwap sync.Mutex
 
-var SiguienteOffsetLibre int64 = 0
+// El swapfile está dividido en slots del tamaño de una página, true = libre
+var BitmapSwap []bool
+var MutexSwap sync.Mutex
 
 var MutexProcesos sync.Mutex
 var MutexBitmapMarcosLibres sync.Mutex
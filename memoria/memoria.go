@@ -25,17 +25,28 @@ func main() {
 	mux.HandleFunc("/finalizarProceso", memoriaUtils.FinalizarProceso)
 	mux.HandleFunc("/suspenderProceso", memoriaUtils.SuspenderProceso)
 	mux.HandleFunc("/desuspenderProceso", memoriaUtils.DesuspenderProceso)
+	mux.HandleFunc("/cargarPagina", memoriaUtils.CargarPagina)
+	mux.HandleFunc("/forkProceso", memoriaUtils.ForkProceso)
+	mux.HandleFunc("/shmCrear", memoriaUtils.ShmCrear)
+	mux.HandleFunc("/shmAdjuntar", memoriaUtils.ShmAdjuntar)
+	mux.HandleFunc("/shmDesadjuntar", memoriaUtils.ShmDesadjuntar)
+	mux.HandleFunc("/cambiarTamanio", memoriaUtils.CambiarTamanio)
 
 	// Endpoints que reciben peticiones desde CPU
+	mux.HandleFunc("/cpus", memoriaUtils.RegistrarCpu)
 	mux.HandleFunc("/obtenerConfiguracionMemoria", memoriaUtils.ObtenerConfiguracionMemoria)
 	mux.HandleFunc("/siguienteInstruccion", memoriaUtils.SiguienteInstruccion)
 	mux.HandleFunc("/accederMarcoUsuario", memoriaUtils.AccederMarcoUsuario)
+	mux.HandleFunc("/accederMarcoEscritura", memoriaUtils.AccederMarcoEscritura)
 	mux.HandleFunc("/readPagina", memoriaUtils.LeerPagina)
 	mux.HandleFunc("/writePagina", memoriaUtils.EscribirPagina)
 	mux.HandleFunc("/writeMemoria", memoriaUtils.EscribirDireccionFisica)
 	mux.HandleFunc("/readMemoria", memoriaUtils.LeerDireccionFisica)
 	mux.HandleFunc("/memoryDump", memoriaUtils.DumpMemoria)
 
+	// Consulta del uso del swap
+	mux.HandleFunc("/swap", memoriaUtils.UsoSwap)
+
 	// Levanta el servidor en el puerto definido por configuración
 	direccion := fmt.Sprintf("%s:%d", globalsMemoria.MemoriaConfig.IpMemory, globalsMemoria.MemoriaConfig.PortMemory)
 	fmt.Printf("[Memoria] Servidor escuchando en puerto %d...\n", globalsMemoria.MemoriaConfig.PortMemory)
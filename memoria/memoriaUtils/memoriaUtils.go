@@ -2,17 +2,20 @@ package memoriaUtils
 
 import (
 	"encoding/json"
+	"fmt"
 	"reflect" //eliminar despues de probar que funciona
 	"strconv"
 	"strings"
 	"time"
 
-	//"errors"
+	"errors"
 	"net/http"
 	"os"
 
 	globalsMemoria "github.com/sisoputnfrba/tp-golang/memoria/globalsMemoria"
 	clientUtils "github.com/sisoputnfrba/tp-golang/utils/client"
+	ensamblador "github.com/sisoputnfrba/tp-golang/utils/ensamblador"
+	instrucciones "github.com/sisoputnfrba/tp-golang/utils/instrucciones"
 	serverUtils "github.com/sisoputnfrba/tp-golang/utils/server"
 )
 
@@ -115,7 +118,41 @@ func IniciarProceso(w http.ResponseWriter, r *http.Request) {
 		return
 	} //esto tamien contempla problemas con el path
 
-	listaInstrucciones := ParsearInstrucciones(instruccionesSinParsear)
+	listaInstrucciones, err := ParsearInstrucciones(instruccionesSinParsear)
+	if err != nil {
+		// Un script mal escrito no se arregla esperando: el Kernel lo distingue de la falta de espacio
+		clientUtils.Logger.Error("Error al ensamblar el script:", "path", pedido.Valores[FILE_PATH], "error", err)
+		http.Error(w, "Script inválido: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if globalsMemoria.MemoriaConfig.ValidarScripts {
+		opciones := instrucciones.Opciones{TamanioProceso: size, Dispositivos: globalsMemoria.MemoriaConfig.DispositivosIO}
+		if diagnosticos := instrucciones.Validar(listaInstrucciones, opciones); len(diagnosticos) > 0 {
+			var mensajes []string
+			for _, diagnostico := range diagnosticos {
+				mensajes = append(mensajes, "PC "+strconv.Itoa(diagnostico.PC)+": "+diagnostico.Mensaje)
+			}
+			clientUtils.Logger.Error("Script rechazado por el validador:", "path", pedido.Valores[FILE_PATH], "errores", mensajes)
+			http.Error(w, "Script inválido: "+strings.Join(mensajes, "; "), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+
+	if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		// Alcanza con tener libres los marcos que se le reservan, las páginas se cargan con cada PAGE_FAULT
+		if countMarcosLibres() < cantidadMarcosPropios(size) {
+			http.Error(w, "Espacio en memoria insuficiete.", http.StatusBadRequest)
+			return
+		}
+		if !asignarMemoriaPorDemanda(pid, listaInstrucciones, size) {
+			http.Error(w, "Error al asignar memoria", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		clientUtils.Logger.Info("Se crea el proceso", "PID", pid, "Tamaño", pedido.Valores[SIZE], "Marcos", cantidadMarcosPropios(size))
+		return
+	}
 
 	if EspacioLibre() < size {
 		http.Error(w, "Espacio en memoria insuficiete.", http.StatusBadRequest)
@@ -153,8 +190,13 @@ func FinalizarProceso(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Liberar los marcos de memoria asignados al proceso seteando el bitmap a true
+	// Soltar los segmentos compartidos y liberar los marcos de memoria asignados al proceso seteando el bitmap a true
+	invalidarTLB(pid)
+	desadjuntarSegmentos(proceso, "")
+	liberarSegmentosSinAdjuntar(pid)
 	liberarTabla(&proceso.TablaPaginasGlobal, 1)
+	liberarMarcosPropios(proceso)
+	liberarSwapDeProceso(proceso)
 
 	// Eliminar el proceso del slice ProcesosEnMemoria
 	for i := range globalsMemoria.ProcesosEnMemoria {
@@ -225,7 +267,29 @@ func SiguienteInstruccion(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(instruccion))
 }
 
+// Respuesta de Memoria a un acceso fuera del proceso o sin permiso, seguida del motivo. La CPU
+// devuelve el proceso al Kernel con SEG_FAULT y el Kernel lo finaliza
+const SEG_FAULT = "SEG_FAULT"
+
+// Marca que sigue al marco en la respuesta de una traducción cuando la página es de un segmento
+// de memoria compartida. La CPU no la guarda en la caché, porque otros procesos escriben el marco
+const COMPARTIDA = "COMPARTIDA"
+
+// Marca de la traducción de la última página del proceso cuando el tamaño termina antes que la
+// página. La CPU no la guarda en la TLB, así cada acceso a esa página vuelve a controlarse acá
+const PARCIAL = "PARCIAL"
+
 func AccederMarcoUsuario(w http.ResponseWriter, r *http.Request) {
+	accederMarco(w, r, false)
+}
+
+// Igual que accederMarcoUsuario, pero la CPU va a escribir en la página: si es copy-on-write
+// se le da al proceso su propio marco antes de responder
+func AccederMarcoEscritura(w http.ResponseWriter, r *http.Request) {
+	accederMarco(w, r, true)
+}
+
+func accederMarco(w http.ResponseWriter, r *http.Request, escritura bool) {
 	//clientUtils.Logger.Info("[Memoria] Petición para acceder a un marco de usuario recibida desde CPU")
 
 	pedido := serverUtils.RecibirPaquetes(w, r)
@@ -289,13 +353,21 @@ func AccederMarcoUsuario(w http.ResponseWriter, r *http.Request) {
 
 	// Acceder recursivamente a las tablas de páginas si hay mas de un nivel
 
+	for _, mov := range movimientos {
+		if mov < 0 || mov >= len(actual.Entradas) {
+			clientUtils.Logger.Error("Entrada de tabla fuera de rango", "valor", mov)
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Una entrada vacía en cualquier nivel es una dirección que el proceso no tiene
 	for nivel := 0; nivel < len(movimientos)-1; nivel++ {
 		time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.MemoryDelay) * time.Millisecond)
 		mov := movimientos[nivel]
 		tabla, ok := actual.Entradas[mov].(*globalsMemoria.TablaPaginas)
 		if !ok {
-			clientUtils.Logger.Error("Error: se esperaba tabla en nivel", "nivel", nivel)
-			http.Error(w, "Estructura incorrecta", http.StatusInternalServerError)
+			rechazarAcceso(w, pid, "página inexistente")
 			return
 		}
 		proceso.Metricas.AccesosATablas++
@@ -306,16 +378,50 @@ func AccederMarcoUsuario(w http.ResponseWriter, r *http.Request) {
 	ultimoMovimiento := movimientos[len(movimientos)-1]
 	pagina, ok := actual.Entradas[ultimoMovimiento].(*globalsMemoria.Pagina)
 	if !ok {
-		clientUtils.Logger.Error("Error: se esperaba página en último nivel pero se encontró", "tipo", reflect.TypeOf(actual.Entradas[ultimoMovimiento]), "nivel", len(movimientos)-1, "movimiento", ultimoMovimiento)
-		http.Error(w, "No se encontró la página", http.StatusInternalServerError)
+		clientUtils.Logger.Debug("Se esperaba página en último nivel pero se encontró", "tipo", reflect.TypeOf(actual.Entradas[ultimoMovimiento]), "nivel", len(movimientos)-1, "movimiento", ultimoMovimiento)
+		rechazarAcceso(w, pid, "página inexistente")
 		return
 	}
 	time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.MemoryDelay) * time.Millisecond)
+
+	desplazamiento, _ := strconv.Atoi(pedido.Valores[len(pedido.Valores)-1])
+	if motivo := violacionDeAcceso(proceso, pagina, desplazamiento, 1, escritura); motivo != "" {
+		rechazarAcceso(w, pid, motivo)
+		return
+	}
+
+	pagina.MutexPagina.Lock()
+	presente := pagina.Presencia
+	pagina.BitUso = true
+	pagina.UltimoUso = time.Now()
+	pagina.MutexPagina.Unlock()
+
+	// La CPU devuelve el proceso al Kernel, que pide cargar la página con /cargarPagina
+	if !presente {
+		clientUtils.Logger.Info("Page fault", "pid", pid, "pagina", pagina.Numero)
+		http.Error(w, PAGE_FAULT, http.StatusConflict)
+		return
+	}
+
+	if escritura {
+		// Sin marco para la copia la escritura no puede hacerse: la CPU devuelve el proceso como SEG_FAULT
+		if err := romperCopiaEnEscritura(pid, pagina); err != nil {
+			rechazarAcceso(w, pid, err.Error())
+			return
+		}
+	}
+
 	direccionFisica := pagina.Marco
 	//clientUtils.Logger.Info("Marco de usuario accedido", "pid", pid, "marco", direccionFisica)
 
+	respuesta := strconv.Itoa(direccionFisica)
+	if pagina.Segmento != "" {
+		respuesta += " " + COMPARTIDA
+	} else if (pagina.Numero+1)*globalsMemoria.MemoriaConfig.PageSize > proceso.Size {
+		respuesta += " " + PARCIAL
+	}
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(strconv.Itoa(direccionFisica)))
+	w.Write([]byte(respuesta))
 }
 
 func LeerPagina(w http.ResponseWriter, r *http.Request) {
@@ -361,7 +467,14 @@ func LeerPagina(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// La caché trae la página entera aunque el final quede fuera del proceso, se controla el comienzo.
+	// Lo que la CPU lee después de la caché está controlado por la traducción de su último byte
 	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	if motivo := validarAccesoFisico(proceso, marco*pageSize, 1, false); motivo != "" {
+		rechazarAcceso(w, pid, motivo)
+		return
+	}
+
 	inicio := marco * pageSize
 	fin := inicio + pageSize
 	if fin > len(globalsMemoria.MemoriaUsuario) {
@@ -372,6 +485,7 @@ func LeerPagina(w http.ResponseWriter, r *http.Request) {
 	contenido := make([]byte, pageSize)
 	copy(contenido, globalsMemoria.MemoriaUsuario[inicio:fin])
 
+	marcarUsada(proceso, marco)
 	proceso.Metricas.LecturasDeMemoria++
 	//clientUtils.Logger.Info("Página leída", "pid", pid, "marco", marco)
 
@@ -431,6 +545,10 @@ func EscribirPagina(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Marco no asignado", http.StatusBadRequest)
 		return
 	}
+	if motivo := validarAccesoFisico(proceso, marco*pageSize, 1, true); motivo != "" {
+		rechazarAcceso(w, pid, motivo)
+		return
+	}
 
 	inicio := marco * pageSize
 	fin := inicio + pageSize
@@ -453,6 +571,7 @@ func EscribirPagina(w http.ResponseWriter, r *http.Request) {
 		globalsMemoria.MemoriaUsuario[inicio+i] = byte(contenido)
 	}
 
+	marcarModificada(proceso, marco)
 	proceso.Metricas.EscriturasDeMemoria++
 
 	time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.MemoryDelay) * time.Millisecond)
@@ -462,6 +581,8 @@ func EscribirPagina(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// Recibe [PID, dirección física] o [PID, dirección física, tamaño] y devuelve esos bytes. El rango
+// no puede pasar a la página siguiente
 func LeerDireccionFisica(w http.ResponseWriter, r *http.Request) {
 	//clientUtils.Logger.Info("[Memoria] Petición para leer dirección física recibida desde CPU")
 	time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.MemoryDelay) * time.Millisecond)
@@ -469,6 +590,10 @@ func LeerDireccionFisica(w http.ResponseWriter, r *http.Request) {
 	pedido := serverUtils.RecibirPaquetes(w, r)
 
 	//clientUtils.Logger.Debug("Los valores recibidos en leerDireccionFisica", "valores: ", pedido.Valores)
+	if len(pedido.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
 
 	pid, err := strconv.Atoi(pedido.Valores[0])
 	if err != nil {
@@ -489,17 +614,31 @@ func LeerDireccionFisica(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	tamanio := 1
+	if len(pedido.Valores) > 2 {
+		if tamanio, err = strconv.Atoi(pedido.Valores[2]); err != nil || tamanio < 1 {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+	}
+	if motivo := validarAccesoFisico(proceso, direccionFisica, tamanio, false); motivo != "" {
+		rechazarAcceso(w, pid, motivo)
+		return
+	}
 
-	contenido := globalsMemoria.MemoriaUsuario[direccionFisica]
+	contenido := append([]byte{}, globalsMemoria.MemoriaUsuario[direccionFisica:direccionFisica+tamanio]...)
 	// Simulamos la escritura de la dirección física
+	marcarUsada(proceso, direccionFisica/globalsMemoria.MemoriaConfig.PageSize)
 	proceso.Metricas.LecturasDeMemoria++
 
 	clientUtils.Logger.Info("Lectura de dirección física", "pid", pid, "direccion_fisica", direccionFisica, "contenido", contenido)
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte{contenido})
+	w.Write(contenido)
 
 }
 
+// Recibe [PID, dirección física, valor...]: escribe los bytes a partir de la dirección en un solo
+// pedido, sin pisar el resto de la página. El rango no puede pasar a la página siguiente
 func EscribirDireccionFisica(w http.ResponseWriter, r *http.Request) {
 	//clientUtils.Logger.Info("[Memoria] Petición para escribir dirección física recibida desde CPU")
 	time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.MemoryDelay) * time.Millisecond)
@@ -507,6 +646,10 @@ func EscribirDireccionFisica(w http.ResponseWriter, r *http.Request) {
 	pedido := serverUtils.RecibirPaquetes(w, r)
 
 	//clientUtils.Logger.Debug("Los valores recibidos en escribirDireccionFisica", "valores: ", pedido.Valores)
+	if len(pedido.Valores) < 3 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
 
 	pid, err := strconv.Atoi(pedido.Valores[0])
 	if err != nil {
@@ -527,21 +670,135 @@ func EscribirDireccionFisica(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
+	if motivo := validarAccesoFisico(proceso, direccionFisica, len(pedido.Valores)-2, true); motivo != "" {
+		rechazarAcceso(w, pid, motivo)
+		return
+	}
 	// Esta parte es clave:
-	valorNumerico, err := strconv.Atoi(pedido.Valores[2])
+	contenido := make([]byte, 0, len(pedido.Valores)-2)
+	for _, valor := range pedido.Valores[2:] {
+		valorNumerico, err := strconv.Atoi(valor)
+		if err != nil || valorNumerico < 0 || valorNumerico > 255 {
+			clientUtils.Logger.Error("Error al parsear valor a byte")
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		contenido = append(contenido, byte(valorNumerico))
+	}
+
+	proceso.Metricas.EscriturasDeMemoria++
+	copy(globalsMemoria.MemoriaUsuario[direccionFisica:], contenido)
+	marcarModificada(proceso, direccionFisica/globalsMemoria.MemoriaConfig.PageSize)
+	clientUtils.Logger.Info("Escritura en dirección física", "pid", pid, "direccion_fisica", direccionFisica)
+
+	w.WriteHeader(http.StatusOK)
+
+}
+
+// Recibe ["IDENTIFICADOR", "IP", "PUERTO"] de una CPU que conserva la TLB entre procesos
+func RegistrarCpu(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 3 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	puerto, err := strconv.Atoi(pedido.Valores[2])
 	if err != nil {
-		clientUtils.Logger.Error("Error al parsear valor a byte")
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	contenido := byte(valorNumerico)
 
-	proceso.Metricas.EscriturasDeMemoria++
-	globalsMemoria.MemoriaUsuario[direccionFisica] = contenido
-	clientUtils.Logger.Info("Escritura en dirección física", "pid", pid, "direccion_fisica", direccionFisica)
+	globalsMemoria.MutexCpus.Lock()
+	globalsMemoria.Cpus = append(globalsMemoria.Cpus, globalsMemoria.Cpu{Identificador: pedido.Valores[0], Ip: pedido.Valores[1], Puerto: puerto})
+	globalsMemoria.MutexCpus.Unlock()
 
+	clientUtils.Logger.Info("CPU registrada", "identificador", pedido.Valores[0], "ip", pedido.Valores[1], "puerto", puerto)
 	w.WriteHeader(http.StatusOK)
+}
+
+// Avisa a las CPUs registradas que descarten las traducciones de esas páginas del proceso, o de
+// todas si no se pasa ninguna. Se espera la respuesta para que ninguna use después el marco viejo
+func invalidarTLB(pid int, paginas ...int) {
+	globalsMemoria.MutexCpus.Lock()
+	cpus := append([]globalsMemoria.Cpu{}, globalsMemoria.Cpus...)
+	globalsMemoria.MutexCpus.Unlock()
+	if len(cpus) == 0 {
+		return
+	}
+
+	valores := []string{strconv.Itoa(pid)}
+	for _, pagina := range paginas {
+		valores = append(valores, strconv.Itoa(pagina))
+	}
+	for _, cpu := range cpus {
+		clientUtils.EnviarPaquete(cpu.Ip, cpu.Puerto, "invalidarTLB", clientUtils.Paquete{Valores: valores})
+	}
+	clientUtils.Logger.Debug("Invalidación de TLB", "pid", pid, "paginas", paginas, "cpus", len(cpus))
+}
+
+func rechazarAcceso(w http.ResponseWriter, pid int, motivo string) {
+	clientUtils.Logger.Error("Segmentation fault", "pid", pid, "motivo", motivo)
+	http.Error(w, SEG_FAULT+": "+motivo, http.StatusForbidden)
+}
+
+// Devuelve por qué el proceso no puede acceder a esos bytes de la página, o "" si puede. Las páginas
+// de un segmento compartido están fuera del tamaño del proceso y no se controlan contra él
+func violacionDeAcceso(proceso *globalsMemoria.Proceso, pagina *globalsMemoria.Pagina, desplazamiento int, tamanio int, escritura bool) string {
+	direccion := pagina.Numero*globalsMemoria.MemoriaConfig.PageSize + desplazamiento
+	if ultima := direccion + tamanio - 1; pagina.Segmento == "" && ultima >= proceso.Size {
+		return fmt.Sprintf("dirección %d fuera del proceso de %d bytes", ultima, proceso.Size)
+	}
+	// Una página copy-on-write no tiene permiso de escritura hasta que se copia, pero se puede escribir
+	if escritura && !pagina.Permisos.Escritura && !pagina.CopiaEnEscritura {
+		return fmt.Sprintf("página %d sin permiso de escritura", pagina.Numero)
+	}
+	if !escritura && !pagina.Permisos.Lectura {
+		return fmt.Sprintf("página %d sin permiso de lectura", pagina.Numero)
+	}
+	return ""
+}
+
+// Los accesos por dirección física también se controlan: el marco tiene que ser de una página
+// cargada del proceso y el acceso tiene que estar permitido en esa página
+func validarAccesoFisico(proceso *globalsMemoria.Proceso, direccionFisica int, tamanio int, escritura bool) string {
+	if direccionFisica < 0 || direccionFisica+tamanio > len(globalsMemoria.MemoriaUsuario) {
+		return fmt.Sprintf("dirección física %d fuera de la memoria", direccionFisica)
+	}
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	if direccionFisica/pageSize != (direccionFisica+tamanio-1)/pageSize {
+		return fmt.Sprintf("el acceso de %d bytes en la dirección física %d pasa al marco siguiente", tamanio, direccionFisica)
+	}
+	marco := direccionFisica / pageSize
+	pagina := paginaEnMarco(&proceso.TablaPaginasGlobal, 1, marco)
+	if pagina == nil {
+		return fmt.Sprintf("el marco %d no es del proceso", marco)
+	}
+	return violacionDeAcceso(proceso, pagina, direccionFisica%pageSize, tamanio, escritura)
+}
 
+// Página cargada en el marco, o nil si ninguna página de la tabla lo ocupa
+func paginaEnMarco(tabla *globalsMemoria.TablaPaginas, nivelActual int, marco int) *globalsMemoria.Pagina {
+	for _, entrada := range tabla.Entradas {
+		if nivelActual < globalsMemoria.MemoriaConfig.NumberOfLevels {
+			if subtabla, ok := entrada.(*globalsMemoria.TablaPaginas); ok {
+				if pagina := paginaEnMarco(subtabla, nivelActual+1, marco); pagina != nil {
+					return pagina
+				}
+			}
+			continue
+		}
+		pagina, ok := entrada.(*globalsMemoria.Pagina)
+		if !ok {
+			continue
+		}
+		pagina.MutexPagina.Lock()
+		encontrada := pagina.Presencia && pagina.Marco == marco
+		pagina.MutexPagina.Unlock()
+		if encontrada {
+			return pagina
+		}
+	}
+	return nil
 }
 
 func ObtenerConfiguracionMemoria(w http.ResponseWriter, r *http.Request) {
@@ -592,43 +849,57 @@ func SuspenderProceso(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Con paginación por demanda cada página ya tiene su lugar en swap: solo se bajan las modificadas
+	if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		if err := suspenderPorDemanda(proceso); err != nil {
+			clientUtils.Logger.Error("Error al suspender proceso:", "pid", pid, "error", err)
+			if errors.Is(err, errSwapLleno) {
+				http.Error(w, "Swap lleno", http.StatusInsufficientStorage)
+				return
+			}
+			http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
+			return
+		}
+		invalidarTLB(pid)
+		time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.SwapDelay) * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Proceso suspendido exitosamente"))
+		return
+	}
+
 	// Leer contenido real de las páginas del proceso
 	paginas := leerPaginasDeTabla(&proceso.TablaPaginasGlobal, 1)
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
 
-	swapFile, err := os.OpenFile(globalsMemoria.MemoriaConfig.SwapfilePath, os.O_WRONLY|os.O_CREATE, 0644)
-	if err != nil {
-		clientUtils.Logger.Error("Error al abrir swapfile:", "error", err)
-		http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
+	// Si no hay slots para todas las páginas el proceso queda en memoria como estaba
+	slots, ok := reservarSlotsSwap(len(paginas) / pageSize)
+	if !ok {
+		clientUtils.Logger.Error("Swap lleno, no se puede suspender el proceso", "pid", pid, "paginas", len(paginas)/pageSize)
+		http.Error(w, "Swap lleno", http.StatusInsufficientStorage)
 		return
 	}
-	defer swapFile.Close()
 
-	// Posicionar puntero en el offset libre
-	offset := globalsMemoria.SiguienteOffsetLibre
-	if _, err := swapFile.Seek(offset, 0); err != nil {
-		clientUtils.Logger.Error("Error al posicionar en swapfile:", "error", err)
-		http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
-		return
-	}
-	n, err := swapFile.Write(paginas)
-	// Escribir las páginas en swap
-	if err != nil {
-		clientUtils.Logger.Error("Error al escribir en swapfile:", "error", err)
-		http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
-		return
+	// Escribir cada página en su slot
+	for i, slot := range slots {
+		if err := escribirEnSwap(slot, paginas[i*pageSize:(i+1)*pageSize]); err != nil {
+			clientUtils.Logger.Error("Error al escribir en swapfile:", "error", err)
+			liberarSlotsSwap(slots)
+			http.Error(w, "Error interno del servidor", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	// Actualizar tabla de swap (con lock)
 	globalsMemoria.MutexTablaSwap.Lock()
 	globalsMemoria.TablaSwap[pid] = globalsMemoria.ProcesoEnSwap{
-		Pid:    pid,
-		Offset: offset,
-		Size:   n,
+		Pid:   pid,
+		Size:  len(paginas),
+		Slots: slots,
 	}
-	globalsMemoria.SiguienteOffsetLibre += int64(n)
 	globalsMemoria.MutexTablaSwap.Unlock()
 
 	// Liberar marcos (protegido por MutexMemoria)
+	invalidarTLB(pid)
 	liberarTabla(&proceso.TablaPaginasGlobal, 1)
 
 	proceso.Metricas.BajadasASwap++
@@ -658,29 +929,38 @@ func DesuspenderProceso(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "PID no existe", http.StatusNotFound)
 		return
 	}
+
+	// Con paginación por demanda solo se le vuelven a reservar los marcos, las páginas suben con cada PAGE_FAULT
+	if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		if !desuspenderPorDemanda(proceso) {
+			http.Error(w, "Espacio en memoria insuficiete.", http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Proceso desuspendido exitosamente"))
+		return
+	}
+
 	globalsMemoria.MutexTablaSwap.Lock()
 	entrada, ok := globalsMemoria.TablaSwap[pid]
 	globalsMemoria.MutexTablaSwap.Unlock()
 
+	// Si la suspensión había fallado el proceso nunca dejó la memoria
 	if !ok {
-		http.Error(w, "PID no encontrado en TablaSwap", http.StatusNotFound)
-		return
-	}
-
-	swapFile, err := os.Open(globalsMemoria.MemoriaConfig.SwapfilePath)
-	if err != nil {
-		http.Error(w, "Error al abrir swapfile", http.StatusInternalServerError)
+		clientUtils.Logger.Info("Proceso no está en swap, sigue en memoria", "pid", pid)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("Proceso desuspendido exitosamente"))
 		return
 	}
-	defer swapFile.Close()
 
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
 	contenido := make([]byte, entrada.Size)
-
-	_, err = swapFile.ReadAt(contenido, entrada.Offset)
-	if err != nil {
-		clientUtils.Logger.Error("Error al leer swapfile:", "error", err)
-		http.Error(w, "Error al leer swapfile", http.StatusInternalServerError)
-		return
+	for i, slot := range entrada.Slots {
+		if err := leerDeSwap(slot, contenido[i*pageSize:(i+1)*pageSize]); err != nil {
+			clientUtils.Logger.Error("Error al leer swapfile:", "error", err)
+			http.Error(w, "Error al leer swapfile", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	if !reAsignarMemoria(pid, contenido, proceso.Size) {
@@ -733,6 +1013,7 @@ func reAsignarMemoria(pid int, contenidoTotal []byte, size int) bool {
 		}
 		marcosAsignados = append(marcosAsignados, marco)
 		pagina := globalsMemoria.NewPagina(marco, true, true, true)
+		pagina.Numero = i
 
 		inicio := i * pageSize
 		fin := min((i+1)*pageSize, size)
@@ -756,8 +1037,9 @@ func reAsignarMemoria(pid int, contenidoTotal []byte, size int) bool {
 		}
 	}
 
-	//  limpiar la entradas swap
+	//  limpiar la entradas swap y dejar sus slots para otros procesos
 	globalsMemoria.MutexTablaSwap.Lock()
+	liberarSlotsSwap(globalsMemoria.TablaSwap[pid].Slots)
 	delete(globalsMemoria.TablaSwap, pid)
 	//clientUtils.Logger.Debug("Tabla de swap limpiada para el proceso", "pid", pid)
 	globalsMemoria.MutexTablaSwap.Unlock()
@@ -804,10 +1086,18 @@ func DumpMemoria(w http.ResponseWriter, r *http.Request) {
 
 	archivoDump.Sync() // Asegurarse de que los datos se escriban en el disco
 
-	archivoDump.Write(leerPaginasDeTabla(&proceso.TablaPaginasGlobal, 1)) //algo asi para escribir las paginas de memoria
+	compartidas := 0
+	if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		// las páginas que no están cargadas se leen de swap
+		archivoDump.Write(leerProcesoCompleto(proceso))
+	} else {
+		// cada página se lee con su mutex, así una copia por copy-on-write no la cambia a mitad de la lectura
+		archivoDump.Write(leerPaginasDeTabla(&proceso.TablaPaginasGlobal, 1)) //algo asi para escribir las paginas de memoria
+		compartidas = contarPaginasCompartidas(&proceso.TablaPaginasGlobal, 1)
+	}
 	archivoDump.Sync()
 
-	clientUtils.Logger.Info("Dump de memoria creado exitosamente:", "archivo", archivoDump.Name())
+	clientUtils.Logger.Info("Dump de memoria creado exitosamente:", "archivo", archivoDump.Name(), "paginas_compartidas", compartidas)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Dump de memoria creado exitosamente"))
 }
@@ -818,17 +1108,9 @@ func DumpMemoria(w http.ResponseWriter, r *http.Request) {
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 ///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
-func ParsearInstrucciones(archivo []byte) []string {
-	todasLasInstrucciones := string(archivo)
-	lineas := strings.Split(todasLasInstrucciones, "\n")
-	var instrucciones []string
-	for _, linea := range lineas {
-		instruccion := strings.TrimSpace(linea)
-		if instruccion != "" {
-			instrucciones = append(instrucciones, instruccion)
-		}
-	}
-	return instrucciones
+// Resuelve etiquetas, comentarios y constantes del script y devuelve una instrucción por PC
+func ParsearInstrucciones(archivo []byte) ([]string, error) {
+	return ensamblador.Ensamblar(archivo)
 }
 
 func buscarProceso(pid int) *globalsMemoria.Proceso {
@@ -892,6 +1174,7 @@ func asignarMemoria(pid int, instrucciones []string, size int) bool {
 		}
 		marcosAsignados = append(marcosAsignados, marco)
 		pagina := globalsMemoria.NewPagina(marco, true, true, true)
+		pagina.Numero = i
 
 		inicio := i * pageSize
 		fin := min((i+1)*pageSize, size)
@@ -1010,12 +1293,11 @@ func liberarTabla(tabla *globalsMemoria.TablaPaginas, nivelActual int) {
 			// Es una página real
 			pagina, ok := entrada.(*globalsMemoria.Pagina)
 
-			if ok && pagina.Presencia {
+			// Las páginas de un segmento compartido quedan fijas en memoria, sus marcos son del segmento
+			if ok && pagina.Presencia && pagina.Segmento == "" {
 
 				pagina.MutexPagina.Lock()
-				globalsMemoria.MutexBitmapMarcosLibres.Lock()
-				globalsMemoria.BitmapMarcosLibres[pagina.Marco] = true
-				globalsMemoria.MutexBitmapMarcosLibres.Unlock()
+				soltarMarco(pagina.Marco)
 
 				pagina.Presencia = false // Marcar la página como no válida
 
@@ -1032,6 +1314,20 @@ func liberarTabla(tabla *globalsMemoria.TablaPaginas, nivelActual int) {
 	}
 }
 
+// Devuelve el marco al bitmap. Un marco compartido por un FORK queda libre recién cuando lo suelta
+// la última página
+func soltarMarco(marco int) {
+	globalsMemoria.MutexBitmapMarcosLibres.Lock()
+	defer globalsMemoria.MutexBitmapMarcosLibres.Unlock()
+	if referencias := globalsMemoria.MarcosCompartidos[marco]; referencias > 2 {
+		globalsMemoria.MarcosCompartidos[marco]--
+	} else if referencias == 2 {
+		delete(globalsMemoria.MarcosCompartidos, marco)
+	} else {
+		globalsMemoria.BitmapMarcosLibres[marco] = true
+	}
+}
+
 func leerPaginasDeTabla(tabla *globalsMemoria.TablaPaginas, nivelActual int) []byte {
 	var paginasEnMemoria []byte
 
@@ -1042,7 +1338,7 @@ func leerPaginasDeTabla(tabla *globalsMemoria.TablaPaginas, nivelActual int) []b
 
 		if nivelActual == globalsMemoria.MemoriaConfig.NumberOfLevels {
 			pagina, ok := entrada.(*globalsMemoria.Pagina)
-			if ok && pagina.Presencia {
+			if ok && pagina.Presencia && pagina.Segmento == "" {
 				pagina.MutexPagina.Lock()
 				marco := pagina.Marco
 				inicio := marco * globalsMemoria.MemoriaConfig.PageSize
@@ -1067,3 +1363,1129 @@ func EspacioLibre() int {
 	var espacioLibre int = marcosLibres * globalsMemoria.MemoriaConfig.PageSize
 	return espacioLibre
 }
+
+///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+////////////////////////////////////////////----------PAGINACION POR DEMANDA----------/////////////////////////////////////
+///////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
+
+// Respuesta de /accederMarcoUsuario cuando la página no está en memoria
+const PAGE_FAULT = "PAGE_FAULT"
+
+// Kernel pide cargar una página que dio PAGE_FAULT. Recibe ["PID", "PAGINA"]
+func CargarPagina(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	pid, err := strconv.Atoi(pedido.Valores[PID])
+	if err != nil {
+		clientUtils.Logger.Error("Error al parsear PID")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	nroPagina, err := strconv.Atoi(pedido.Valores[1])
+	if err != nil {
+		clientUtils.Logger.Error("Error al parsear número de página")
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	proceso := buscarProceso(pid)
+	if proceso == nil {
+		clientUtils.Logger.Error("Proceso no encontrado:", "pid especifico", pid)
+		http.Error(w, "PID no existe", http.StatusNotFound)
+		return
+	}
+
+	if err := cargarPagina(proceso, nroPagina); err != nil {
+		clientUtils.Logger.Error("Error al cargar página:", "pid", pid, "pagina", nroPagina, "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// FORK: recibe [PID padre, PID hijo] y crea al hijo con las mismas páginas que el padre.
+// Los marcos se comparten sin permiso de escritura y se copian recién cuando alguno escribe
+func ForkProceso(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pidPadre, errPadre := strconv.Atoi(pedido.Valores[0])
+	pidHijo, errHijo := strconv.Atoi(pedido.Valores[1])
+	if errPadre != nil || errHijo != nil || pidHijo < 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	// Con paginación por demanda cada proceso tiene sus marcos fijos, no se pueden compartir
+	if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		http.Error(w, "FORK no disponible con paginación por demanda", http.StatusNotImplemented)
+		return
+	}
+
+	padre := buscarProceso(pidPadre)
+	if padre == nil {
+		http.Error(w, "PID no existe", http.StatusNotFound)
+		return
+	}
+	if buscarProceso(pidHijo) != nil {
+		http.Error(w, "PID ya existe", http.StatusConflict)
+		return
+	}
+	globalsMemoria.MutexTablaSwap.Lock()
+	_, enSwap := globalsMemoria.TablaSwap[pidPadre]
+	globalsMemoria.MutexTablaSwap.Unlock()
+	if enSwap {
+		http.Error(w, "El proceso está suspendido", http.StatusConflict)
+		return
+	}
+
+	hijo := &globalsMemoria.Proceso{Pid: pidHijo,
+		Size:               padre.Size,
+		Instrucciones:      padre.Instrucciones,
+		TablaPaginasGlobal: globalsMemoria.NewTablaPaginas(1),
+	}
+	compartirTabla(&padre.TablaPaginasGlobal, &hijo.TablaPaginasGlobal, 1)
+	// Las traducciones para escribir del padre apuntan a marcos que ahora son copy-on-write
+	invalidarTLB(pidPadre)
+
+	globalsMemoria.MutexSegmentos.Lock()
+	for _, segmento := range globalsMemoria.SegmentosCompartidos {
+		if base, ok := segmento.Procesos[pidPadre]; ok {
+			segmento.Procesos[pidHijo] = base
+		}
+	}
+	globalsMemoria.MutexSegmentos.Unlock()
+
+	globalsMemoria.MutexProcesos.Lock()
+	globalsMemoria.ProcesosEnMemoria = append(globalsMemoria.ProcesosEnMemoria, hijo)
+	globalsMemoria.MutexProcesos.Unlock()
+
+	clientUtils.Logger.Info("Se crea el proceso por FORK", "PID", pidHijo, "Padre", pidPadre, "Tamaño", hijo.Size)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Copia la jerarquía de tablas apuntando a los mismos marcos. Las páginas de los dos lados
+// quedan sin permiso de escritura
+func compartirTabla(tabla *globalsMemoria.TablaPaginas, copia *globalsMemoria.TablaPaginas, nivelActual int) {
+	for i, entrada := range tabla.Entradas {
+		if nivelActual < globalsMemoria.MemoriaConfig.NumberOfLevels {
+			if subtabla, ok := entrada.(*globalsMemoria.TablaPaginas); ok {
+				subcopia := globalsMemoria.NewTablaPaginas(nivelActual + 1)
+				compartirTabla(subtabla, &subcopia, nivelActual+1)
+				copia.Entradas[i] = &subcopia
+			}
+			continue
+		}
+		pagina, ok := entrada.(*globalsMemoria.Pagina)
+		if !ok {
+			continue
+		}
+
+		pagina.MutexPagina.Lock()
+		nueva := globalsMemoria.NewPagina(pagina.Marco, pagina.Presencia, pagina.Permisos.Escritura, pagina.Permisos.Lectura)
+		nueva.Numero = pagina.Numero
+		nueva.Segmento = pagina.Segmento
+		// Un segmento compartido se hereda adjunto, sin copy-on-write
+		if pagina.Presencia && pagina.Segmento == "" {
+			if pagina.Permisos.Escritura || pagina.CopiaEnEscritura {
+				pagina.Permisos.Escritura = false
+				pagina.CopiaEnEscritura = true
+				nueva.Permisos.Escritura = false
+				nueva.CopiaEnEscritura = true
+			}
+			globalsMemoria.MutexBitmapMarcosLibres.Lock()
+			globalsMemoria.MarcosCompartidos[pagina.Marco] = max(globalsMemoria.MarcosCompartidos[pagina.Marco], 1) + 1
+			globalsMemoria.MutexBitmapMarcosLibres.Unlock()
+		}
+		pagina.MutexPagina.Unlock()
+		copia.Entradas[i] = &nueva
+	}
+}
+
+// Primera escritura sobre una página copy-on-write: si el marco lo sigue usando otro proceso se
+// copia a un marco libre, si no la página simplemente recupera el permiso de escritura
+func romperCopiaEnEscritura(pid int, pagina *globalsMemoria.Pagina) error {
+	copiada, err := copiarPaginaCompartida(pid, pagina)
+	if err != nil {
+		return err
+	}
+	// Otra CPU puede tener la traducción al marco compartido de cuando el proceso ejecutó ahí. El
+	// aviso es un pedido a cada CPU, así que se manda con los locks ya soltados
+	if copiada {
+		invalidarTLB(pid, pagina.Numero)
+	}
+	return nil
+}
+
+// Devuelve si la página pasó a un marco nuevo
+func copiarPaginaCompartida(pid int, pagina *globalsMemoria.Pagina) (bool, error) {
+	pagina.MutexPagina.Lock()
+	defer pagina.MutexPagina.Unlock()
+	if !pagina.CopiaEnEscritura {
+		return false, nil
+	}
+
+	globalsMemoria.MutexBitmapMarcosLibres.Lock()
+	defer globalsMemoria.MutexBitmapMarcosLibres.Unlock()
+
+	copiada := false
+	if referencias := globalsMemoria.MarcosCompartidos[pagina.Marco]; referencias > 1 {
+		nuevo := -1
+		for i, libre := range globalsMemoria.BitmapMarcosLibres {
+			if libre {
+				nuevo = i
+				break
+			}
+		}
+		if nuevo == -1 {
+			return false, fmt.Errorf("no hay marcos libres para copiar la página")
+		}
+		globalsMemoria.BitmapMarcosLibres[nuevo] = false
+
+		pageSize := globalsMemoria.MemoriaConfig.PageSize
+		copy(globalsMemoria.MemoriaUsuario[nuevo*pageSize:(nuevo+1)*pageSize], globalsMemoria.MemoriaUsuario[pagina.Marco*pageSize:(pagina.Marco+1)*pageSize])
+		if referencias == 2 {
+			delete(globalsMemoria.MarcosCompartidos, pagina.Marco)
+		} else {
+			globalsMemoria.MarcosCompartidos[pagina.Marco]--
+		}
+		clientUtils.Logger.Info("Copy-on-write", "pid", pid, "pagina", pagina.Numero, "marco_compartido", pagina.Marco, "marco_nuevo", nuevo)
+		pagina.Marco = nuevo
+		copiada = true
+	}
+
+	pagina.Permisos.Escritura = true
+	pagina.CopiaEnEscritura = false
+	return copiada, nil
+}
+
+// Cantidad de páginas del proceso cuyo marco comparte con otro
+func contarPaginasCompartidas(tabla *globalsMemoria.TablaPaginas, nivelActual int) int {
+	cantidad := 0
+	for _, entrada := range tabla.Entradas {
+		if subtabla, ok := entrada.(*globalsMemoria.TablaPaginas); ok && nivelActual < globalsMemoria.MemoriaConfig.NumberOfLevels {
+			cantidad += contarPaginasCompartidas(subtabla, nivelActual+1)
+		} else if pagina, ok := entrada.(*globalsMemoria.Pagina); ok {
+			pagina.MutexPagina.Lock()
+			globalsMemoria.MutexBitmapMarcosLibres.Lock()
+			if pagina.Presencia && globalsMemoria.MarcosCompartidos[pagina.Marco] > 1 {
+				cantidad++
+			}
+			globalsMemoria.MutexBitmapMarcosLibres.Unlock()
+			pagina.MutexPagina.Unlock()
+		}
+	}
+	return cantidad
+}
+
+// SHM_CREATE: recibe [PID, clave, tamaño] y reserva los marcos del segmento. Si ya existe con
+// al menos ese tamaño se usa el existente, así varios procesos pueden crearlo sin coordinarse
+func ShmCrear(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 3 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, errPid := strconv.Atoi(pedido.Valores[0])
+	clave := pedido.Valores[1]
+	size, errSize := strconv.Atoi(pedido.Valores[2])
+	if errPid != nil || errSize != nil || clave == "" || size <= 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	globalsMemoria.MutexSegmentos.Lock()
+	defer globalsMemoria.MutexSegmentos.Unlock()
+
+	if segmento, ok := globalsMemoria.SegmentosCompartidos[clave]; ok {
+		if segmento.Size < size {
+			http.Error(w, "El segmento ya existe con un tamaño menor", http.StatusConflict)
+			return
+		}
+		if !segmento.Usado {
+			segmento.Creadores[pid] = true
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	marcos, ok := reservarMarcos((size + pageSize - 1) / pageSize)
+	if !ok {
+		http.Error(w, "Espacio en memoria insuficiete.", http.StatusInsufficientStorage)
+		return
+	}
+	for _, marco := range marcos {
+		clear(globalsMemoria.MemoriaUsuario[marco*pageSize : (marco+1)*pageSize])
+	}
+	globalsMemoria.SegmentosCompartidos[clave] = &globalsMemoria.SegmentoCompartido{
+		Clave:     clave,
+		Size:      size,
+		Marcos:    marcos,
+		Procesos:  map[int]int{},
+		Creadores: map[int]bool{pid: true},
+	}
+
+	clientUtils.Logger.Info("Se crea el segmento compartido", "PID", pid, "Clave", clave, "Tamaño", size, "Marcos", len(marcos))
+	w.WriteHeader(http.StatusOK)
+}
+
+// SHM_ATTACH: recibe [PID, clave, dirección lógica base] y mapea los marcos del segmento en la
+// tabla de páginas del proceso a partir de esa dirección, que tiene que estar alineada a página
+// y libre
+func ShmAdjuntar(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 3 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, errPid := strconv.Atoi(pedido.Valores[0])
+	clave := pedido.Valores[1]
+	base, errBase := strconv.Atoi(pedido.Valores[2])
+	if errPid != nil || errBase != nil || base < 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	if base%pageSize != 0 {
+		http.Error(w, "La dirección base no está alineada a página", http.StatusBadRequest)
+		return
+	}
+
+	proceso := buscarProceso(pid)
+	if proceso == nil {
+		http.Error(w, "PID no existe", http.StatusNotFound)
+		return
+	}
+
+	globalsMemoria.MutexSegmentos.Lock()
+	defer globalsMemoria.MutexSegmentos.Unlock()
+
+	segmento, ok := globalsMemoria.SegmentosCompartidos[clave]
+	if !ok {
+		http.Error(w, "Segmento no existe", http.StatusNotFound)
+		return
+	}
+	if _, adjunto := segmento.Procesos[pid]; adjunto {
+		http.Error(w, "El segmento ya está adjunto al proceso", http.StatusConflict)
+		return
+	}
+
+	// Las páginas tienen que entrar en la tabla y no pisar páginas propias ni de otro segmento
+	primera := base / pageSize
+	maximoPaginas := 1
+	for range globalsMemoria.MemoriaConfig.NumberOfLevels {
+		maximoPaginas *= globalsMemoria.MemoriaConfig.EntriesPerPage
+	}
+	if primera+len(segmento.Marcos) > maximoPaginas {
+		http.Error(w, "El segmento no entra en la tabla de páginas", http.StatusConflict)
+		return
+	}
+	for i := range segmento.Marcos {
+		if primera+i < (proceso.Size+pageSize-1)/pageSize || paginaOcupada(proceso, primera+i) {
+			http.Error(w, "El rango de direcciones ya está en uso", http.StatusConflict)
+			return
+		}
+	}
+
+	for i, marco := range segmento.Marcos {
+		pagina := globalsMemoria.NewPagina(marco, true, true, true)
+		pagina.Numero = primera + i
+		pagina.Segmento = clave
+		if insertarPaginaEnJerarquia(&proceso.TablaPaginasGlobal, &pagina, primera+i, globalsMemoria.MemoriaConfig.NumberOfLevels) {
+			quitarPaginas(proceso, primera, i)
+			http.Error(w, "Error al insertar página en jerarquía", http.StatusInternalServerError)
+			return
+		}
+	}
+	segmento.Procesos[pid] = primera
+	segmento.Usado = true
+
+	clientUtils.Logger.Info("Segmento compartido adjuntado", "PID", pid, "Clave", clave, "Base", base, "Procesos", len(segmento.Procesos))
+	w.WriteHeader(http.StatusOK)
+}
+
+// SHM_DETACH: recibe [PID] para soltar todos los segmentos del proceso o [PID, clave] para uno solo
+func ShmDesadjuntar(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 1 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, err := strconv.Atoi(pedido.Valores[0])
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	proceso := buscarProceso(pid)
+	if proceso == nil {
+		http.Error(w, "PID no existe", http.StatusNotFound)
+		return
+	}
+
+	clave := ""
+	if len(pedido.Valores) > 1 {
+		clave = pedido.Valores[1]
+	}
+	if desadjuntarSegmentos(proceso, clave) == 0 && clave != "" {
+		http.Error(w, "El segmento no está adjunto al proceso", http.StatusNotFound)
+		return
+	}
+	invalidarTLB(pid)
+	w.WriteHeader(http.StatusOK)
+}
+
+// Saca de la tabla del proceso las páginas del segmento con esa clave, o de todos si la clave es "".
+// El segmento que se queda sin procesos devuelve sus marcos. Devuelve cuántos segmentos soltó
+func desadjuntarSegmentos(proceso *globalsMemoria.Proceso, clave string) int {
+	globalsMemoria.MutexSegmentos.Lock()
+	defer globalsMemoria.MutexSegmentos.Unlock()
+
+	soltados := 0
+	for _, segmento := range globalsMemoria.SegmentosCompartidos {
+		primera, adjunto := segmento.Procesos[proceso.Pid]
+		if !adjunto || (clave != "" && segmento.Clave != clave) {
+			continue
+		}
+		quitarPaginas(proceso, primera, len(segmento.Marcos))
+		delete(segmento.Procesos, proceso.Pid)
+		soltados++
+		clientUtils.Logger.Info("Segmento compartido desadjuntado", "PID", proceso.Pid, "Clave", segmento.Clave, "Procesos", len(segmento.Procesos))
+
+		if len(segmento.Procesos) == 0 && segmento.Usado {
+			devolverMarcos(segmento.Marcos)
+			delete(globalsMemoria.SegmentosCompartidos, segmento.Clave)
+			clientUtils.Logger.Info("Se libera el segmento compartido", "Clave", segmento.Clave)
+		}
+	}
+	return soltados
+}
+
+// Un segmento que nadie adjuntó no tiene procesos que lo suelten: se libera cuando terminan
+// todos los procesos que lo crearon
+func liberarSegmentosSinAdjuntar(pid int) {
+	globalsMemoria.MutexSegmentos.Lock()
+	defer globalsMemoria.MutexSegmentos.Unlock()
+
+	for clave, segmento := range globalsMemoria.SegmentosCompartidos {
+		if segmento.Usado || !segmento.Creadores[pid] {
+			continue
+		}
+		delete(segmento.Creadores, pid)
+		if len(segmento.Creadores) == 0 {
+			devolverMarcos(segmento.Marcos)
+			delete(globalsMemoria.SegmentosCompartidos, clave)
+			clientUtils.Logger.Info("Se libera el segmento compartido que no se adjuntó", "Clave", clave)
+		}
+	}
+}
+
+func paginaOcupada(proceso *globalsMemoria.Proceso, nroPagina int) bool {
+	niveles := globalsMemoria.MemoriaConfig.NumberOfLevels
+	actual := &proceso.TablaPaginasGlobal
+	for nivel := 1; nivel < niveles; nivel++ {
+		tabla, ok := actual.Entradas[calcularIndice(nroPagina, nivel)].(*globalsMemoria.TablaPaginas)
+		if !ok {
+			return false
+		}
+		actual = tabla
+	}
+	return actual.Entradas[calcularIndice(nroPagina, niveles)] != nil
+}
+
+// Borra de la tabla del proceso cantidad páginas a partir de primera, sin tocar sus marcos
+func quitarPaginas(proceso *globalsMemoria.Proceso, primera int, cantidad int) {
+	niveles := globalsMemoria.MemoriaConfig.NumberOfLevels
+	for nroPagina := primera; nroPagina < primera+cantidad; nroPagina++ {
+		actual := &proceso.TablaPaginasGlobal
+		for nivel := 1; nivel < niveles && actual != nil; nivel++ {
+			actual, _ = actual.Entradas[calcularIndice(nroPagina, nivel)].(*globalsMemoria.TablaPaginas)
+		}
+		if actual != nil {
+			actual.Entradas[calcularIndice(nroPagina, niveles)] = nil
+		}
+	}
+}
+
+// BRK: recibe [PID, tamaño nuevo] y agrega o saca páginas al final del espacio lógico del proceso
+func CambiarTamanio(w http.ResponseWriter, r *http.Request) {
+	pedido := serverUtils.RecibirPaquetes(w, r)
+	if len(pedido.Valores) < 2 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	pid, errPid := strconv.Atoi(pedido.Valores[0])
+	nuevoSize, errSize := strconv.Atoi(pedido.Valores[1])
+	if errPid != nil || errSize != nil || nuevoSize < 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	proceso := buscarProceso(pid)
+	if proceso == nil {
+		http.Error(w, "PID no existe", http.StatusNotFound)
+		return
+	}
+
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	actuales := (proceso.Size + pageSize - 1) / pageSize
+	nuevas := (nuevoSize + pageSize - 1) / pageSize
+
+	var err error
+	if nuevas > actuales {
+		err = agregarPaginas(proceso, actuales, nuevas)
+	} else if nuevas < actuales {
+		sacarPaginas(proceso, nuevas, actuales)
+	}
+	if err != nil {
+		clientUtils.Logger.Error("No se pudo cambiar el tamaño del proceso", "pid", pid, "tamaño", nuevoSize, "error", err)
+		http.Error(w, err.Error(), http.StatusInsufficientStorage)
+		return
+	}
+
+	clientUtils.Logger.Info("Se cambia el tamaño del proceso", "PID", pid, "Anterior", proceso.Size, "Nuevo", nuevoSize, "Paginas", nuevas)
+	proceso.Size = nuevoSize
+
+	// Si la última página que queda pasa a estar usada a medias, su traducción no puede seguir en la TLB
+	if nuevoSize%pageSize != 0 && nuevas <= actuales {
+		invalidarTLB(pid, nuevas-1)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// Inserta las páginas [desde, hasta) en la tabla del proceso. Sin paginación por demanda cada una
+// necesita un marco libre ya mismo; con demanda entran ausentes y se cargan con el primer acceso
+func agregarPaginas(proceso *globalsMemoria.Proceso, desde int, hasta int) error {
+	maximoPaginas := 1
+	for range globalsMemoria.MemoriaConfig.NumberOfLevels {
+		maximoPaginas *= globalsMemoria.MemoriaConfig.EntriesPerPage
+	}
+	if hasta > maximoPaginas {
+		return fmt.Errorf("la tabla de páginas admite hasta %d páginas, se pidieron %d", maximoPaginas, hasta)
+	}
+	for nroPagina := desde; nroPagina < hasta; nroPagina++ {
+		if paginaOcupada(proceso, nroPagina) {
+			return fmt.Errorf("la página %d ya está ocupada por un segmento compartido", nroPagina)
+		}
+	}
+
+	demanda := globalsMemoria.MemoriaConfig.PaginacionPorDemanda
+	var marcos []int
+	if !demanda {
+		var ok bool
+		if marcos, ok = reservarMarcos(hasta - desde); !ok {
+			return fmt.Errorf("sin marcos libres: se necesitan %d y hay %d", hasta-desde, countMarcosLibres())
+		}
+	}
+
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+	if err := ajustarMarcosPropios(proceso, hasta); err != nil {
+		return err
+	}
+	for i := 0; i < hasta-desde; i++ {
+		pagina := globalsMemoria.NewPagina(-1, false, true, true)
+		if !demanda {
+			pagina = globalsMemoria.NewPagina(marcos[i], true, true, true)
+			clear(globalsMemoria.MemoriaUsuario[marcos[i]*pageSize : (marcos[i]+1)*pageSize])
+		}
+		pagina.Numero = desde + i
+		if insertarPaginaEnJerarquia(&proceso.TablaPaginasGlobal, &pagina, desde+i, globalsMemoria.MemoriaConfig.NumberOfLevels) {
+			quitarPaginas(proceso, desde, i)
+			devolverMarcos(marcos)
+			ajustarMarcosPropios(proceso, desde)
+			return fmt.Errorf("error al insertar la página %d en la jerarquía", desde+i)
+		}
+	}
+	return nil
+}
+
+// Saca las páginas [desde, hasta) de la tabla del proceso y devuelve sus marcos y sus slots de swap
+func sacarPaginas(proceso *globalsMemoria.Proceso, desde int, hasta int) {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+
+	for nroPagina := desde; nroPagina < hasta; nroPagina++ {
+		pagina := buscarPagina(proceso, nroPagina)
+		if pagina == nil {
+			continue
+		}
+		pagina.MutexPagina.Lock()
+		if pagina.SlotSwap >= 0 {
+			liberarSlotsSwap([]int{pagina.SlotSwap})
+		}
+		if pagina.Presencia {
+			if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+				quitarResidente(proceso, pagina)
+			} else {
+				soltarMarco(pagina.Marco)
+			}
+		}
+		pagina.Presencia = false
+		pagina.MutexPagina.Unlock()
+	}
+	quitarPaginas(proceso, desde, hasta-desde)
+	ajustarMarcosPropios(proceso, desde)
+
+	paginas := make([]int, 0, hasta-desde)
+	for nroPagina := desde; nroPagina < hasta; nroPagina++ {
+		paginas = append(paginas, nroPagina)
+	}
+	invalidarTLB(proceso.Pid, paginas...)
+}
+
+// Con paginación por demanda los marcos propios acompañan al tamaño del proceso, igual que al
+// crearlo. Los que sobran se devuelven desde el final, donde no hay páginas residentes. Un proceso
+// suspendido no tiene marcos: los reserva según su tamaño al volver
+func ajustarMarcosPropios(proceso *globalsMemoria.Proceso, paginas int) error {
+	if !globalsMemoria.MemoriaConfig.PaginacionPorDemanda || proceso.Suspendido {
+		return nil
+	}
+	necesarios := cantidadMarcosPropios(paginas * globalsMemoria.MemoriaConfig.PageSize)
+	if faltan := necesarios - len(proceso.Marcos); faltan > 0 {
+		nuevos, ok := reservarMarcos(faltan)
+		if !ok {
+			return fmt.Errorf("sin marcos libres: se necesitan %d y hay %d", faltan, countMarcosLibres())
+		}
+		proceso.Marcos = append(proceso.Marcos, nuevos...)
+	} else if necesarios < len(proceso.Marcos) && necesarios >= len(proceso.Residentes) {
+		devolverMarcos(proceso.Marcos[necesarios:])
+		proceso.Marcos = proceso.Marcos[:necesarios]
+	}
+	return nil
+}
+
+// Con paginación por demanda el marco de una página residente sigue siendo del proceso. Se mantiene
+// que Residentes[i] ocupa Marcos[i] pasando el marco liberado detrás de los que están en uso
+func quitarResidente(proceso *globalsMemoria.Proceso, pagina *globalsMemoria.Pagina) {
+	ultimo := len(proceso.Residentes) - 1
+	for i, residente := range proceso.Residentes {
+		if residente != pagina {
+			continue
+		}
+		proceso.Residentes[i] = proceso.Residentes[ultimo]
+		proceso.Marcos[i], proceso.Marcos[ultimo] = proceso.Marcos[ultimo], proceso.Marcos[i]
+		proceso.Residentes = proceso.Residentes[:ultimo]
+		if proceso.PunteroVictima >= len(proceso.Residentes) {
+			proceso.PunteroVictima = 0
+		}
+		return
+	}
+}
+
+// Marcos fijos que se le reservan a un proceso de ese tamaño
+func cantidadMarcosPropios(size int) int {
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	totalPaginas := (size + pageSize - 1) / pageSize
+	if limite := globalsMemoria.MemoriaConfig.MarcosPorProceso; limite > 0 && limite < totalPaginas {
+		return limite
+	}
+	return max(totalPaginas, 1)
+}
+
+// Crea el proceso con todas sus páginas ausentes y le reserva sus marcos. Una página recién
+// ocupa un slot de swap la primera vez que se desaloja modificada.
+func asignarMemoriaPorDemanda(pid int, instrucciones []string, size int) bool {
+	if pid < 0 {
+		clientUtils.Logger.Error("PID negativo no permitido")
+		return false
+	}
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	totalPaginas := (size + pageSize - 1) / pageSize
+
+	nuevoProceso := &globalsMemoria.Proceso{Pid: pid,
+		Size:               size,
+		Instrucciones:      instrucciones,
+		TablaPaginasGlobal: globalsMemoria.NewTablaPaginas(1),
+	}
+
+	marcos, ok := reservarMarcos(cantidadMarcosPropios(size))
+	if !ok {
+		clientUtils.Logger.Error("No hay marcos libres disponibles para asignar memoria")
+		return false
+	}
+	nuevoProceso.Marcos = marcos
+
+	for i := 0; i < totalPaginas; i++ {
+		pagina := globalsMemoria.NewPagina(-1, false, true, true)
+		pagina.Numero = i
+		if err := insertarPaginaEnJerarquia(&nuevoProceso.TablaPaginasGlobal, &pagina, i, globalsMemoria.MemoriaConfig.NumberOfLevels); err {
+			clientUtils.Logger.Error("Error al insertar página en jerarquía", "pid", pid, "pagina", i)
+			liberarMarcosPropios(nuevoProceso)
+			return false
+		}
+	}
+
+	globalsMemoria.MutexProcesos.Lock()
+	globalsMemoria.ProcesosEnMemoria = append(globalsMemoria.ProcesosEnMemoria, nuevoProceso)
+	globalsMemoria.MutexProcesos.Unlock()
+	return true
+}
+
+// Toma cantidad marcos libres del bitmap, o ninguno si no alcanzan
+func reservarMarcos(cantidad int) ([]int, bool) {
+	marcos := make([]int, 0, cantidad)
+	for len(marcos) < cantidad {
+		marco := buscarMarcoLibre()
+		if marco == -1 {
+			devolverMarcos(marcos)
+			return nil, false
+		}
+		marcos = append(marcos, marco)
+	}
+	return marcos, true
+}
+
+func devolverMarcos(marcos []int) {
+	globalsMemoria.MutexBitmapMarcosLibres.Lock()
+	defer globalsMemoria.MutexBitmapMarcosLibres.Unlock()
+	for _, marco := range marcos {
+		globalsMemoria.BitmapMarcosLibres[marco] = true
+	}
+}
+
+func liberarMarcosPropios(proceso *globalsMemoria.Proceso) {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+	devolverMarcos(proceso.Marcos)
+	proceso.Marcos = nil
+	proceso.Residentes = nil
+	proceso.PunteroVictima = 0
+}
+
+func buscarPagina(proceso *globalsMemoria.Proceso, nroPagina int) *globalsMemoria.Pagina {
+	niveles := globalsMemoria.MemoriaConfig.NumberOfLevels
+	actual := &proceso.TablaPaginasGlobal
+	for nivel := 1; nivel < niveles; nivel++ {
+		indice := calcularIndice(nroPagina, nivel)
+		if indice < 0 {
+			return nil
+		}
+		tabla, ok := actual.Entradas[indice].(*globalsMemoria.TablaPaginas)
+		if !ok {
+			return nil
+		}
+		actual = tabla
+	}
+	indice := calcularIndice(nroPagina, niveles)
+	if indice < 0 {
+		return nil
+	}
+	pagina, ok := actual.Entradas[indice].(*globalsMemoria.Pagina)
+	if !ok || pagina.Numero != nroPagina {
+		return nil
+	}
+	return pagina
+}
+
+// Trae la página a uno de los marcos del proceso, reemplazando una de sus páginas si están todos ocupados
+func cargarPagina(proceso *globalsMemoria.Proceso, nroPagina int) error {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+
+	// Se suspendió mientras esperaba la carga: la página va a volver a fallar cuando se desuspenda
+	if proceso.Suspendido {
+		return nil
+	}
+
+	pagina := buscarPagina(proceso, nroPagina)
+	if pagina == nil {
+		return fmt.Errorf("la página %d no pertenece al proceso", nroPagina)
+	}
+	if pagina.Presencia {
+		return nil
+	}
+	if len(proceso.Marcos) == 0 {
+		return fmt.Errorf("el proceso no tiene marcos asignados")
+	}
+
+	var marco int
+	if len(proceso.Residentes) < len(proceso.Marcos) {
+		marco = proceso.Marcos[len(proceso.Residentes)]
+		proceso.Residentes = append(proceso.Residentes, pagina)
+	} else {
+		indice := elegirVictima(proceso)
+		victima := proceso.Residentes[indice]
+		if err := desalojarPagina(proceso, victima); err != nil {
+			return err
+		}
+		clientUtils.Logger.Info("Reemplazo de página", "pid", proceso.Pid, "victima", victima.Numero, "nueva", nroPagina, "marco", victima.Marco, "algoritmo", globalsMemoria.MemoriaConfig.AlgoritmoReemplazo)
+		marco = victima.Marco
+		proceso.Residentes[indice] = pagina
+	}
+
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	destino := globalsMemoria.MemoriaUsuario[marco*pageSize : (marco+1)*pageSize]
+	if pagina.SlotSwap >= 0 {
+		if err := leerDeSwap(pagina.SlotSwap, destino); err != nil {
+			return err
+		}
+	} else {
+		clear(destino)
+	}
+
+	pagina.MutexPagina.Lock()
+	pagina.Marco = marco
+	pagina.Presencia = true
+	pagina.BitUso = true
+	pagina.BitModificado = false
+	pagina.UltimoUso = time.Now()
+	pagina.MutexPagina.Unlock()
+
+	proceso.Metricas.SubidasAMemoria++
+	time.Sleep(time.Duration(globalsMemoria.MemoriaConfig.SwapDelay) * time.Millisecond)
+	return nil
+}
+
+// Saca la página de su marco, bajándola a swap si fue modificada. La primera vez que baja se le
+// reserva un slot que conserva hasta que termina el proceso.
+func desalojarPagina(proceso *globalsMemoria.Proceso, pagina *globalsMemoria.Pagina) error {
+	pagina.MutexPagina.Lock()
+	defer pagina.MutexPagina.Unlock()
+
+	if pagina.BitModificado {
+		if pagina.SlotSwap < 0 {
+			slots, ok := reservarSlotsSwap(1)
+			if !ok {
+				return errSwapLleno
+			}
+			pagina.SlotSwap = slots[0]
+		}
+		pageSize := globalsMemoria.MemoriaConfig.PageSize
+		contenido := globalsMemoria.MemoriaUsuario[pagina.Marco*pageSize : (pagina.Marco+1)*pageSize]
+		if err := escribirEnSwap(pagina.SlotSwap, contenido); err != nil {
+			return err
+		}
+		proceso.Metricas.BajadasASwap++
+	}
+	pagina.Presencia = false
+	pagina.BitModificado = false
+	invalidarTLB(proceso.Pid, pagina.Numero)
+	return nil
+}
+
+// Índice en Residentes de la página a reemplazar según algoritmo_reemplazo
+func elegirVictima(proceso *globalsMemoria.Proceso) int {
+	residentes := proceso.Residentes
+	avanzar := func() {
+		proceso.PunteroVictima = (proceso.PunteroVictima + 1) % len(residentes)
+	}
+
+	switch strings.ToUpper(globalsMemoria.MemoriaConfig.AlgoritmoReemplazo) {
+	case "FIFO":
+		// los reemplazos son en el lugar, así que recorrer en círculo respeta el orden de llegada
+		victima := proceso.PunteroVictima % len(residentes)
+		proceso.PunteroVictima = victima
+		avanzar()
+		return victima
+
+	case "LRU":
+		// Es una aproximación: Memoria solo ve las traducciones y los accesos que no resuelven
+		// la TLB o la caché de la CPU, así que UltimoUso es el último acceso que llegó hasta acá
+		victima := 0
+		for i, pagina := range residentes {
+			if pagina.UltimoUso.Before(residentes[victima].UltimoUso) {
+				victima = i
+			}
+		}
+		return victima
+
+	case "CLOCK-M":
+		for {
+			// primera vuelta: (u=0, m=0) sin tocar nada
+			for range residentes {
+				pagina := residentes[proceso.PunteroVictima]
+				if !pagina.BitUso && !pagina.BitModificado {
+					victima := proceso.PunteroVictima
+					avanzar()
+					return victima
+				}
+				avanzar()
+			}
+			// segunda vuelta: (u=0, m=1) poniendo en 0 el bit de uso
+			for range residentes {
+				pagina := residentes[proceso.PunteroVictima]
+				if !pagina.BitUso && pagina.BitModificado {
+					victima := proceso.PunteroVictima
+					avanzar()
+					return victima
+				}
+				pagina.BitUso = false
+				avanzar()
+			}
+		}
+
+	default: // CLOCK
+		for {
+			pagina := residentes[proceso.PunteroVictima]
+			if !pagina.BitUso {
+				victima := proceso.PunteroVictima
+				avanzar()
+				return victima
+			}
+			pagina.BitUso = false
+			avanzar()
+		}
+	}
+}
+
+// Las escrituras llegan por marco, se busca a qué página residente corresponde para marcarla
+func marcarModificada(proceso *globalsMemoria.Proceso, marco int) {
+	marcarAcceso(proceso, marco, true)
+}
+
+// Las lecturas también cuentan como uso para CLOCK y LRU
+func marcarUsada(proceso *globalsMemoria.Proceso, marco int) {
+	marcarAcceso(proceso, marco, false)
+}
+
+func marcarAcceso(proceso *globalsMemoria.Proceso, marco int, escritura bool) {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+	for _, pagina := range proceso.Residentes {
+		if pagina.Presencia && pagina.Marco == marco {
+			pagina.MutexPagina.Lock()
+			if escritura {
+				pagina.BitModificado = true
+			}
+			pagina.BitUso = true
+			pagina.UltimoUso = time.Now()
+			pagina.MutexPagina.Unlock()
+			return
+		}
+	}
+}
+
+func suspenderPorDemanda(proceso *globalsMemoria.Proceso) error {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+
+	// Los slots de las páginas que bajan por primera vez se reservan todos juntos antes de
+	// desalojar, así con el swap lleno el proceso sigue entero en memoria
+	var sinSlot []*globalsMemoria.Pagina
+	for _, pagina := range proceso.Residentes {
+		if pagina.BitModificado && pagina.SlotSwap < 0 {
+			sinSlot = append(sinSlot, pagina)
+		}
+	}
+	slots, ok := reservarSlotsSwap(len(sinSlot))
+	if !ok {
+		return errSwapLleno
+	}
+	for i, pagina := range sinSlot {
+		pagina.SlotSwap = slots[i]
+	}
+
+	for _, pagina := range proceso.Residentes {
+		if err := desalojarPagina(proceso, pagina); err != nil {
+			return err
+		}
+	}
+	devolverMarcos(proceso.Marcos)
+	proceso.Marcos = nil
+	proceso.Residentes = nil
+	proceso.PunteroVictima = 0
+	proceso.Suspendido = true
+	return nil
+}
+
+func desuspenderPorDemanda(proceso *globalsMemoria.Proceso) bool {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+
+	if !proceso.Suspendido {
+		return true
+	}
+	marcos, ok := reservarMarcos(cantidadMarcosPropios(proceso.Size))
+	if !ok {
+		return false
+	}
+	proceso.Marcos = marcos
+	proceso.Suspendido = false
+	return true
+}
+
+// Contenido de todas las páginas del proceso, estén cargadas, en swap o sin usar todavía
+func leerProcesoCompleto(proceso *globalsMemoria.Proceso) []byte {
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	totalPaginas := (proceso.Size + pageSize - 1) / pageSize
+	contenido := make([]byte, totalPaginas*pageSize)
+	for i := 0; i < totalPaginas; i++ {
+		pagina := buscarPagina(proceso, i)
+		if pagina == nil {
+			continue
+		}
+		destino := contenido[i*pageSize : (i+1)*pageSize]
+		if pagina.Presencia {
+			copy(destino, globalsMemoria.MemoriaUsuario[pagina.Marco*pageSize:(pagina.Marco+1)*pageSize])
+		} else if pagina.SlotSwap >= 0 {
+			if err := leerDeSwap(pagina.SlotSwap, destino); err != nil {
+				clientUtils.Logger.Error("Error al leer página de swap:", "pid", proceso.Pid, "pagina", i, "error", err)
+			}
+		}
+	}
+	return contenido
+}
+
+var errSwapLleno = errors.New("swap lleno")
+
+// Cantidad máxima de slots según swap_max_size, -1 si no hay límite
+func maximoSlotsSwap() int {
+	if globalsMemoria.MemoriaConfig.SwapMaxSize <= 0 {
+		return -1
+	}
+	return globalsMemoria.MemoriaConfig.SwapMaxSize / globalsMemoria.MemoriaConfig.PageSize
+}
+
+// Toma cantidad slots libres del swap, o ninguno si no alcanzan. Primero se reusan los que se
+// liberaron y después el swapfile crece hasta swap_max_size.
+func reservarSlotsSwap(cantidad int) ([]int, bool) {
+	globalsMemoria.MutexSwap.Lock()
+	defer globalsMemoria.MutexSwap.Unlock()
+
+	slots := make([]int, 0, cantidad)
+	for slot := 0; slot < len(globalsMemoria.BitmapSwap) && len(slots) < cantidad; slot++ {
+		if globalsMemoria.BitmapSwap[slot] {
+			slots = append(slots, slot)
+		}
+	}
+	faltan := cantidad - len(slots)
+	if maximo := maximoSlotsSwap(); maximo >= 0 && len(globalsMemoria.BitmapSwap)+faltan > maximo {
+		return nil, false
+	}
+	for range faltan {
+		slots = append(slots, len(globalsMemoria.BitmapSwap))
+		globalsMemoria.BitmapSwap = append(globalsMemoria.BitmapSwap, true)
+	}
+	for _, slot := range slots {
+		globalsMemoria.BitmapSwap[slot] = false
+	}
+	return slots, true
+}
+
+func liberarSlotsSwap(slots []int) {
+	globalsMemoria.MutexSwap.Lock()
+	defer globalsMemoria.MutexSwap.Unlock()
+	for _, slot := range slots {
+		globalsMemoria.BitmapSwap[slot] = true
+	}
+}
+
+// Devuelve los slots que ocupa el proceso, tanto si está suspendido entero como si tiene
+// páginas bajadas por demanda
+func liberarSwapDeProceso(proceso *globalsMemoria.Proceso) {
+	globalsMemoria.MutexTablaSwap.Lock()
+	if entrada, ok := globalsMemoria.TablaSwap[proceso.Pid]; ok {
+		liberarSlotsSwap(entrada.Slots)
+		delete(globalsMemoria.TablaSwap, proceso.Pid)
+	}
+	globalsMemoria.MutexTablaSwap.Unlock()
+
+	if !globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		return
+	}
+	proceso.MutexPaginas.Lock()
+	defer proceso.MutexPaginas.Unlock()
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	for i := 0; i < (proceso.Size+pageSize-1)/pageSize; i++ {
+		if pagina := buscarPagina(proceso, i); pagina != nil && pagina.SlotSwap >= 0 {
+			liberarSlotsSwap([]int{pagina.SlotSwap})
+			pagina.SlotSwap = -1
+		}
+	}
+}
+
+// Estado del swap que devuelve /swap
+type usoSwap struct {
+	TamanioMaximo int         `json:"tamanio_maximo"` // bytes, 0 = sin límite
+	SlotsTotales  int         `json:"slots_totales"`
+	SlotsUsados   int         `json:"slots_usados"`
+	SlotsLibres   int         `json:"slots_libres"`
+	BytesUsados   int         `json:"bytes_usados"`
+	Procesos      map[int]int `json:"procesos"` // PID -> slots que ocupa
+}
+
+func UsoSwap(w http.ResponseWriter, r *http.Request) {
+	pageSize := globalsMemoria.MemoriaConfig.PageSize
+	uso := usoSwap{
+		TamanioMaximo: globalsMemoria.MemoriaConfig.SwapMaxSize,
+		Procesos:      map[int]int{},
+	}
+
+	globalsMemoria.MutexTablaSwap.Lock()
+	for pid, entrada := range globalsMemoria.TablaSwap {
+		uso.Procesos[pid] += len(entrada.Slots)
+	}
+	globalsMemoria.MutexTablaSwap.Unlock()
+
+	if globalsMemoria.MemoriaConfig.PaginacionPorDemanda {
+		globalsMemoria.MutexProcesos.Lock()
+		procesos := append([]*globalsMemoria.Proceso(nil), globalsMemoria.ProcesosEnMemoria...)
+		globalsMemoria.MutexProcesos.Unlock()
+		for _, proceso := range procesos {
+			proceso.MutexPaginas.Lock()
+			for i := 0; i < (proceso.Size+pageSize-1)/pageSize; i++ {
+				if pagina := buscarPagina(proceso, i); pagina != nil && pagina.SlotSwap >= 0 {
+					uso.Procesos[proceso.Pid]++
+				}
+			}
+			proceso.MutexPaginas.Unlock()
+		}
+	}
+
+	globalsMemoria.MutexSwap.Lock()
+	uso.SlotsTotales = len(globalsMemoria.BitmapSwap)
+	for _, libre := range globalsMemoria.BitmapSwap {
+		if !libre {
+			uso.SlotsUsados++
+		}
+	}
+	globalsMemoria.MutexSwap.Unlock()
+	uso.BytesUsados = uso.SlotsUsados * pageSize
+
+	// Con límite, los slots que todavía no se crearon también cuentan como libres
+	if maximo := maximoSlotsSwap(); maximo >= 0 {
+		uso.SlotsLibres = maximo - uso.SlotsUsados
+	} else {
+		uso.SlotsLibres = uso.SlotsTotales - uso.SlotsUsados
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uso)
+}
+
+func escribirEnSwap(slot int, datos []byte) error {
+	offset := int64(slot) * int64(globalsMemoria.MemoriaConfig.PageSize)
+	swapFile, err := os.OpenFile(globalsMemoria.MemoriaConfig.SwapfilePath, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("al abrir swapfile: %w", err)
+	}
+	defer swapFile.Close()
+	if _, err := swapFile.WriteAt(datos, offset); err != nil {
+		return fmt.Errorf("al escribir en swapfile: %w", err)
+	}
+	return nil
+}
+
+func leerDeSwap(slot int, destino []byte) error {
+	offset := int64(slot) * int64(globalsMemoria.MemoriaConfig.PageSize)
+	swapFile, err := os.Open(globalsMemoria.MemoriaConfig.SwapfilePath)
+	if err != nil {
+		return fmt.Errorf("al abrir swapfile: %w", err)
+	}
+	defer swapFile.Close()
+	if _, err := swapFile.ReadAt(destino, offset); err != nil {
+		return fmt.Errorf("al leer swapfile: %w", err)
+	}
+	return nil
+}
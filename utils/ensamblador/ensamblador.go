@@ -0,0 +1,143 @@
+package ensamblador
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	instrucciones "github.com/sisoputnfrba/tp-golang/utils/instrucciones"
+	registros "github.com/sisoputnfrba/tp-golang/utils/registros"
+)
+
+// Error de ensamblado con la línea del archivo fuente donde se produjo
+type Error struct {
+	Linea   int
+	Mensaje string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("línea %d: %s", e.Linea, e.Mensaje)
+}
+
+// Instrucción ya separada de etiquetas y comentarios, con la línea de donde salió
+type lineaFuente struct {
+	numero int
+	campos []string
+}
+
+// Ensamblar resuelve un script de pseudocódigo a la lista de instrucciones que ejecuta la CPU:
+//   - "# ..." es un comentario hasta el final de la línea
+//   - "etiqueta:" define una etiqueta con el PC de la próxima instrucción (puede ir en la misma línea)
+//   - ".equ NOMBRE valor" define una constante
+//
+// Las etiquetas y constantes se reemplazan en los operandos por su valor numérico. Un salto a una
+// etiqueta que no existe es un error.
+func Ensamblar(fuente []byte) ([]string, error) {
+	programa, _, err := EnsamblarConLineas(fuente)
+	return programa, err
+}
+
+// Igual que Ensamblar, pero además devuelve la línea del fuente de cada instrucción
+func EnsamblarConLineas(fuente []byte) ([]string, []int, error) {
+	etiquetas := map[string]int{}
+	constantes := map[string]string{}
+	var lineas []lineaFuente
+
+	// Primera pasada: se juntan etiquetas, constantes e instrucciones
+	for i, linea := range strings.Split(string(fuente), "\n") {
+		numero := i + 1
+		if comentario := strings.Index(linea, "#"); comentario >= 0 {
+			linea = linea[:comentario]
+		}
+		linea = strings.TrimSpace(linea)
+
+		// Solo el primer token puede ser una etiqueta, así un ":" dentro de un operando no se confunde
+		for {
+			primero, _, _ := strings.Cut(linea, " ")
+			dosPuntos := strings.Index(primero, ":")
+			if dosPuntos < 0 {
+				break
+			}
+			etiqueta := strings.TrimSpace(linea[:dosPuntos])
+			if err := validarNombre(etiqueta, etiquetas, constantes); err != nil {
+				return nil, nil, &Error{numero, "etiqueta " + err.Error()}
+			}
+			etiquetas[etiqueta] = len(lineas)
+			linea = strings.TrimSpace(linea[dosPuntos+1:])
+		}
+
+		campos := strings.Fields(linea)
+		if len(campos) == 0 {
+			continue
+		}
+
+		if strings.EqualFold(campos[0], ".equ") {
+			if len(campos) != 3 {
+				return nil, nil, &Error{numero, "se esperaba .equ NOMBRE valor"}
+			}
+			if err := validarNombre(campos[1], etiquetas, constantes); err != nil {
+				return nil, nil, &Error{numero, "constante " + err.Error()}
+			}
+			valor := campos[2]
+			if definido, ok := constantes[valor]; ok {
+				valor = definido
+			}
+			constantes[campos[1]] = valor
+			continue
+		}
+
+		lineas = append(lineas, lineaFuente{numero, campos})
+	}
+
+	// Segunda pasada: se reemplazan los operandos y se validan los saltos
+	programa := make([]string, 0, len(lineas))
+	numeros := make([]int, 0, len(lineas))
+	for _, linea := range lineas {
+		cod_op := linea.campos[0]
+		operandos := linea.campos[1:]
+		for j, operando := range operandos {
+			if pc, ok := etiquetas[operando]; ok {
+				operandos[j] = strconv.Itoa(pc)
+			} else if valor, ok := constantes[operando]; ok {
+				operandos[j] = valor
+			}
+		}
+
+		// Los destinos de salto tienen que haber quedado como un PC
+		if forma, ok := instrucciones.Tabla[strings.ToUpper(cod_op)].Forma(len(operandos)); ok {
+			for j, tipo := range forma {
+				if _, err := strconv.Atoi(operandos[j]); tipo == instrucciones.DESTINO && err != nil {
+					return nil, nil, &Error{linea.numero, fmt.Sprintf("etiqueta no definida: %s", operandos[j])}
+				}
+			}
+		}
+
+		programa = append(programa, strings.Join(append([]string{cod_op}, operandos...), " "))
+		numeros = append(numeros, linea.numero)
+	}
+
+	return programa, numeros, nil
+}
+
+// Un nombre de etiqueta o constante es un identificador que no pisa registros ni otros nombres
+func validarNombre(nombre string, etiquetas map[string]int, constantes map[string]string) error {
+	if nombre == "" {
+		return fmt.Errorf("sin nombre")
+	}
+	for i, c := range nombre {
+		esLetra := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+		if !esLetra && (i == 0 || c < '0' || c > '9') {
+			return fmt.Errorf("inválida: %s", nombre)
+		}
+	}
+	if registros.EsRegistro(nombre) {
+		return fmt.Errorf("con nombre de registro: %s", nombre)
+	}
+	if _, ok := etiquetas[nombre]; ok {
+		return fmt.Errorf("repetida: %s", nombre)
+	}
+	if _, ok := constantes[nombre]; ok {
+		return fmt.Errorf("repetida: %s", nombre)
+	}
+	return nil
+}
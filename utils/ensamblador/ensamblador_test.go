@@ -0,0 +1,123 @@
+package ensamblador
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEnsamblar(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		fuente   string
+		programa []string
+	}{
+		{
+			nombre:   "comentarios y líneas vacías",
+			fuente:   "# encabezado\n\nNOOP # nada\n  EXIT\n",
+			programa: []string{"NOOP", "EXIT"},
+		},
+		{
+			nombre:   "etiqueta en su propia línea y hacia adelante",
+			fuente:   "GOTO fin\nNOOP\nfin:\nEXIT",
+			programa: []string{"GOTO 2", "NOOP", "EXIT"},
+		},
+		{
+			nombre:   "etiqueta en la misma línea que la instrucción",
+			fuente:   "SET AX 3\nciclo: SUB AX 1\nJNZ AX ciclo\nEXIT",
+			programa: []string{"SET AX 3", "SUB AX 1", "JNZ AX 1", "EXIT"},
+		},
+		{
+			nombre:   "constantes, incluso definidas a partir de otra",
+			fuente:   ".equ BASE 0x40\n.equ INICIO BASE\nWRITE INICIO hola\nREAD BASE 4",
+			programa: []string{"WRITE 0x40 hola", "READ 0x40 4"},
+		},
+		{
+			nombre:   "dos puntos dentro de un operando",
+			fuente:   "WRITE 0 a:b",
+			programa: []string{"WRITE 0 a:b"},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			programa, err := Ensamblar([]byte(caso.fuente))
+			if err != nil {
+				t.Fatalf("Ensamblar() devolvió error: %v", err)
+			}
+			if !reflect.DeepEqual(programa, caso.programa) {
+				t.Errorf("Ensamblar() = %q, se esperaba %q", programa, caso.programa)
+			}
+		})
+	}
+}
+
+func TestEnsamblarErrores(t *testing.T) {
+	casos := []struct {
+		nombre  string
+		fuente  string
+		linea   int
+		mensaje string
+	}{
+		{
+			nombre:  "etiqueta no definida",
+			fuente:  "NOOP\nGOTO fin",
+			linea:   2,
+			mensaje: "etiqueta no definida: fin",
+		},
+		{
+			nombre:  "etiqueta repetida",
+			fuente:  "inicio:\nNOOP\ninicio: EXIT",
+			linea:   3,
+			mensaje: "repetida: inicio",
+		},
+		{
+			nombre:  "constante con el nombre de una etiqueta",
+			fuente:  "fin: EXIT\n.equ fin 3",
+			linea:   2,
+			mensaje: "repetida: fin",
+		},
+		{
+			nombre:  "etiqueta con nombre de registro",
+			fuente:  "AX: NOOP",
+			linea:   1,
+			mensaje: "nombre de registro",
+		},
+		{
+			nombre:  "etiqueta inválida",
+			fuente:  "1fin: NOOP",
+			linea:   1,
+			mensaje: "inválida: 1fin",
+		},
+		{
+			nombre:  ".equ sin valor",
+			fuente:  ".equ TAMANIO",
+			linea:   1,
+			mensaje: "se esperaba .equ NOMBRE valor",
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			_, err := Ensamblar([]byte(caso.fuente))
+			var errEnsamblado *Error
+			if !errors.As(err, &errEnsamblado) {
+				t.Fatalf("Ensamblar() = %v, se esperaba un error de ensamblado", err)
+			}
+			if errEnsamblado.Linea != caso.linea || !strings.Contains(errEnsamblado.Mensaje, caso.mensaje) {
+				t.Errorf("Ensamblar() = %v, se esperaba línea %d: ...%s", err, caso.linea, caso.mensaje)
+			}
+		})
+	}
+}
+
+func TestEnsamblarConLineas(t *testing.T) {
+	_, lineas, err := EnsamblarConLineas([]byte("# programa\n.equ N 2\n\nSET AX N\nfin:\n  EXIT"))
+	if err != nil {
+		t.Fatalf("EnsamblarConLineas() devolvió error: %v", err)
+	}
+	if esperadas := []int{4, 6}; !reflect.DeepEqual(lineas, esperadas) {
+		t.Errorf("EnsamblarConLineas() líneas = %v, se esperaba %v", lineas, esperadas)
+	}
+}
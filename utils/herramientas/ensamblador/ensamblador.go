@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	ensamblador "github.com/sisoputnfrba/tp-golang/utils/ensamblador"
+)
+
+// Ensambla un script de pseudocódigo igual que lo hace Memoria al iniciar un proceso y
+// muestra el listado con el PC de cada instrucción. Con -o además guarda el script resuelto.
+// Desde el directorio utils:
+//
+//	go run ./herramientas/ensamblador [-o salida] archivo
+func main() {
+	salida := flag.String("o", "", "archivo donde guardar el script con las etiquetas resueltas")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Uso: ensamblador [-o salida] archivo")
+		os.Exit(2)
+	}
+
+	fuente, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error al leer %s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+
+	instrucciones, err := ensamblador.Ensamblar(fuente)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", flag.Arg(0), err)
+		os.Exit(1)
+	}
+
+	for pc, instruccion := range instrucciones {
+		fmt.Printf("%4d  %s\n", pc, instruccion)
+	}
+
+	if *salida != "" {
+		if err := os.WriteFile(*salida, []byte(strings.Join(instrucciones, "\n")+"\n"), 0644); err != nil {
+			fmt.Fprintf(os.Stderr, "Error al escribir %s: %s\n", *salida, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	ensamblador "github.com/sisoputnfrba/tp-golang/utils/ensamblador"
+	instrucciones "github.com/sisoputnfrba/tp-golang/utils/instrucciones"
+)
+
+// Revisa scripts de pseudocódigo contra la tabla de instrucciones de la CPU, con los mismos
+// controles que hace Memoria cuando tiene validar_scripts activo. Muestra cada problema como
+// archivo:línea: mensaje y termina con error si encontró alguno. Desde el directorio utils:
+//
+//	go run ./herramientas/validador [-tamanio bytes] [-dispositivos DISCO,TECLADO] archivo...
+func main() {
+	tamanio := flag.Int("tamanio", 0, "tamaño del proceso en bytes para controlar READ/WRITE (0 = no se controla)")
+	dispositivos := flag.String("dispositivos", "", "dispositivos de IO válidos separados por coma (vacío = no se controla)")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "Uso: validador [-tamanio bytes] [-dispositivos DISCO,TECLADO] archivo...")
+		os.Exit(2)
+	}
+
+	opciones := instrucciones.Opciones{TamanioProceso: *tamanio}
+	if *dispositivos != "" {
+		opciones.Dispositivos = strings.Split(*dispositivos, ",")
+	}
+
+	errores := 0
+	for _, archivo := range flag.Args() {
+		errores += validarArchivo(archivo, opciones)
+	}
+
+	if errores > 0 {
+		fmt.Fprintf(os.Stderr, "%d error(es)\n", errores)
+		os.Exit(1)
+	}
+}
+
+// Devuelve la cantidad de problemas encontrados en el archivo
+func validarArchivo(archivo string, opciones instrucciones.Opciones) int {
+	fuente, err := os.ReadFile(archivo)
+	if err != nil {
+		fmt.Printf("%s: %s\n", archivo, err)
+		return 1
+	}
+
+	programa, lineas, err := ensamblador.EnsamblarConLineas(fuente)
+	var errorEnsamblado *ensamblador.Error
+	if errors.As(err, &errorEnsamblado) {
+		fmt.Printf("%s:%d: %s\n", archivo, errorEnsamblado.Linea, errorEnsamblado.Mensaje)
+		return 1
+	}
+
+	diagnosticos := instrucciones.Validar(programa, opciones)
+	for _, diagnostico := range diagnosticos {
+		fmt.Printf("%s:%d: %s\n", archivo, lineas[diagnostico.PC], diagnostico.Mensaje)
+	}
+	return len(diagnosticos)
+}
@@ -0,0 +1,269 @@
+package instrucciones
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	registros "github.com/sisoputnfrba/tp-golang/utils/registros"
+)
+
+// Qué se espera en cada operando de una instrucción
+type Operando int
+
+const (
+	TEXTO       Operando = iota // nombre de archivo, recurso o dato a escribir
+	NUMERO                      // entero decimal
+	VALOR                       // número (admite 0x...) o registro
+	REGISTRO                    // nombre de registro
+	DESTINO                     // PC dentro del programa
+	DIRECCION                   // dirección lógica, número o registro
+	DISPOSITIVO                 // nombre de un dispositivo de IO
+)
+
+// Formas válidas de una instrucción, cada una es la lista de operandos que recibe
+type Formato struct {
+	Formas [][]Operando
+}
+
+// Tabla de instrucciones que entiende la CPU
+var Tabla = map[string]Formato{
+	"NOOP":         {[][]Operando{{}}},
+	"EXIT":         {[][]Operando{{}}},
+	"DUMP_MEMORY":  {[][]Operando{{}}},
+	"WRITE":        {[][]Operando{{DIRECCION, TEXTO}}},
+	"READ":         {[][]Operando{{DIRECCION, VALOR}}},
+	"GOTO":         {[][]Operando{{DESTINO}}},
+	"IO":           {[][]Operando{{DISPOSITIVO, NUMERO}}},
+	"INIT_PROC":    {[][]Operando{{TEXTO, NUMERO}, {TEXTO, NUMERO, NUMERO}}},
+	"FORK":         {[][]Operando{{REGISTRO}}},
+	"KILL":         {[][]Operando{{NUMERO}}},
+	"WAIT_PID":     {[][]Operando{{NUMERO}}},
+	"WAIT":         {[][]Operando{{TEXTO}}},
+	"SIGNAL":       {[][]Operando{{TEXTO}}},
+	"MUTEX_LOCK":   {[][]Operando{{TEXTO}}},
+	"MUTEX_UNLOCK": {[][]Operando{{TEXTO}}},
+	"SHM_CREATE":   {[][]Operando{{TEXTO, NUMERO}}},
+	"SHM_ATTACH":   {[][]Operando{{TEXTO, NUMERO}}},
+	"SHM_DETACH":   {[][]Operando{{}, {TEXTO}}},
+	"BRK":          {[][]Operando{{VALOR}}},
+	"SET":          {[][]Operando{{REGISTRO, VALOR}}},
+	"SUM":          {[][]Operando{{REGISTRO, VALOR}}},
+	"SUB":          {[][]Operando{{REGISTRO, VALOR}}},
+	"MUL":          {[][]Operando{{REGISTRO, VALOR}}},
+	"CMP":          {[][]Operando{{VALOR, VALOR}}},
+	"JNZ":          {[][]Operando{{DESTINO}, {REGISTRO, DESTINO}}},
+	"JZ":           {[][]Operando{{DESTINO}, {REGISTRO, DESTINO}}},
+	"MOV_IN":       {[][]Operando{{REGISTRO, REGISTRO}}},
+	"MOV_OUT":      {[][]Operando{{REGISTRO, REGISTRO}}},
+}
+
+// Número literal de un operando: decimal, o hexadecimal con 0x. Un 0 adelante no lo vuelve octal
+func Numero(texto string) (int64, error) {
+	if hexadecimal, ok := strings.CutPrefix(texto, "0x"); ok {
+		return strconv.ParseInt(hexadecimal, 16, 64)
+	}
+	return strconv.ParseInt(texto, 10, 64)
+}
+
+// Devuelve los operandos de la forma con esa cantidad, o false si ninguna la acepta
+func (f Formato) Forma(cantidad int) ([]Operando, bool) {
+	for _, forma := range f.Formas {
+		if len(forma) == cantidad {
+			return forma, true
+		}
+	}
+	return nil, false
+}
+
+// Cantidades de parámetros aceptadas, para los mensajes de error: "2 parametros", "1 o 2 parametros"
+func (f Formato) Aridad() string {
+	cantidades := make([]string, len(f.Formas))
+	for i, forma := range f.Formas {
+		cantidades[i] = strconv.Itoa(len(forma))
+	}
+	switch texto := strings.Join(cantidades, " o "); texto {
+	case "0":
+		return "ningún parametro"
+	case "1":
+		return "1 parametro"
+	default:
+		return texto + " parametros"
+	}
+}
+
+// Problema encontrado en una instrucción del programa
+type Diagnostico struct {
+	PC      int
+	Mensaje string
+}
+
+// Datos opcionales contra los que se valida el programa
+type Opciones struct {
+	TamanioProceso int      // 0 = no se controlan las direcciones
+	Dispositivos   []string // vacío = no se controlan los nombres de IO
+}
+
+// Rango de direcciones [desde, hasta) donde el programa adjunta un segmento compartido.
+// hasta es -1 si el segmento lo crea otro proceso y no se conoce su tamaño
+type zona struct {
+	desde int64
+	hasta int64
+}
+
+// Validar revisa un programa ya ensamblado (una instrucción por PC) contra la tabla de instrucciones
+func Validar(programa []string, opciones Opciones) []Diagnostico {
+	var diagnosticos []Diagnostico
+	zonas := zonasCompartidas(programa)
+	opciones.TamanioProceso = tamanioMaximo(programa, opciones.TamanioProceso)
+	for pc, instruccion := range programa {
+		for _, mensaje := range validarInstruccion(instruccion, len(programa), opciones, zonas) {
+			diagnosticos = append(diagnosticos, Diagnostico{pc, mensaje})
+		}
+	}
+	return diagnosticos
+}
+
+// Un BRK con tamaño literal puede agrandar el proceso, las direcciones se controlan contra el mayor
+func tamanioMaximo(programa []string, tamanioProceso int) int {
+	if tamanioProceso == 0 {
+		return 0
+	}
+	for _, instruccion := range programa {
+		campos := strings.Fields(instruccion)
+		if len(campos) != 2 || campos[0] != "BRK" {
+			continue
+		}
+		if tamanio, err := Numero(campos[1]); err == nil {
+			tamanioProceso = max(tamanioProceso, int(tamanio))
+		}
+	}
+	return tamanioProceso
+}
+
+// Direcciones de los SHM_ATTACH del programa, con el tamaño de su SHM_CREATE si está en el mismo programa
+func zonasCompartidas(programa []string) []zona {
+	tamanios := map[string]int64{}
+	for _, instruccion := range programa {
+		campos := strings.Fields(instruccion)
+		if len(campos) == 3 && campos[0] == "SHM_CREATE" {
+			if tamanio, err := strconv.ParseInt(campos[2], 10, 64); err == nil {
+				tamanios[campos[1]] = tamanio
+			}
+		}
+	}
+
+	var zonas []zona
+	for _, instruccion := range programa {
+		campos := strings.Fields(instruccion)
+		if len(campos) != 3 || campos[0] != "SHM_ATTACH" {
+			continue
+		}
+		base, err := strconv.ParseInt(campos[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		hasta := int64(-1)
+		if tamanio, ok := tamanios[campos[1]]; ok {
+			hasta = base + tamanio
+		}
+		zonas = append(zonas, zona{base, hasta})
+	}
+	return zonas
+}
+
+func validarInstruccion(instruccion string, largoPrograma int, opciones Opciones, zonas []zona) []string {
+	campos := strings.Fields(instruccion)
+	if len(campos) == 0 {
+		return []string{"instrucción vacía"}
+	}
+	cod_op, operandos := campos[0], campos[1:]
+
+	formato, ok := Tabla[cod_op]
+	if !ok {
+		return []string{fmt.Sprintf("instrucción desconocida: %s", cod_op)}
+	}
+	forma, ok := formato.Forma(len(operandos))
+	if !ok {
+		return []string{fmt.Sprintf("%s recibe %s, se recibieron %d", cod_op, formato.Aridad(), len(operandos))}
+	}
+
+	var mensajes []string
+	for i, tipo := range forma {
+		if mensaje := validarOperando(tipo, operandos[i], largoPrograma, opciones); mensaje != "" {
+			mensajes = append(mensajes, fmt.Sprintf("%s: operando %d: %s", cod_op, i+1, mensaje))
+		}
+	}
+	if len(mensajes) == 0 && opciones.TamanioProceso > 0 {
+		if mensaje := validarAcceso(cod_op, operandos, opciones.TamanioProceso, zonas); mensaje != "" {
+			mensajes = append(mensajes, mensaje)
+		}
+	}
+	return mensajes
+}
+
+func validarOperando(tipo Operando, operando string, largoPrograma int, opciones Opciones) string {
+	switch tipo {
+	case NUMERO:
+		if _, err := strconv.Atoi(operando); err != nil {
+			return fmt.Sprintf("se esperaba un número, se recibió %s", operando)
+		}
+	case VALOR, DIRECCION:
+		if _, err := Numero(operando); err != nil && !registros.EsRegistro(operando) {
+			return fmt.Sprintf("se esperaba un número o un registro, se recibió %s", operando)
+		}
+	case REGISTRO:
+		if !registros.EsRegistro(operando) {
+			return fmt.Sprintf("registro inválido: %s", operando)
+		}
+	case DESTINO:
+		destino, err := strconv.Atoi(operando)
+		if err != nil {
+			return fmt.Sprintf("destino inválido: %s", operando)
+		}
+		if destino < 0 || destino >= largoPrograma {
+			return fmt.Sprintf("salto a %d fuera del programa (0 a %d)", destino, largoPrograma-1)
+		}
+	case DISPOSITIVO:
+		if len(opciones.Dispositivos) == 0 {
+			break
+		}
+		for _, dispositivo := range opciones.Dispositivos {
+			if dispositivo == operando {
+				return ""
+			}
+		}
+		return fmt.Sprintf("dispositivo de IO desconocido: %s", operando)
+	}
+	return ""
+}
+
+// READ y WRITE con dirección literal tienen que quedar dentro del tamaño del proceso o de un
+// segmento compartido que adjunte el programa. Con direcciones en registros no se puede saber
+// antes de ejecutar.
+func validarAcceso(cod_op string, operandos []string, tamanioProceso int, zonas []zona) string {
+	if cod_op != "READ" && cod_op != "WRITE" {
+		return ""
+	}
+	direccion, err := Numero(operandos[0])
+	if err != nil {
+		return ""
+	}
+
+	tamanio := int64(len(operandos[1]))
+	if cod_op == "READ" {
+		if tamanio, err = Numero(operandos[1]); err != nil {
+			return ""
+		}
+	}
+
+	for _, compartida := range zonas {
+		if direccion >= compartida.desde && (compartida.hasta < 0 || direccion+tamanio <= compartida.hasta) {
+			return ""
+		}
+	}
+	if direccion < 0 || direccion+tamanio > int64(tamanioProceso) {
+		return fmt.Sprintf("%s: acceso a [%d, %d) fuera del proceso de %d bytes", cod_op, direccion, direccion+tamanio, tamanioProceso)
+	}
+	return ""
+}
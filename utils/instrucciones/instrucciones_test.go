@@ -0,0 +1,108 @@
+package instrucciones
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNumero(t *testing.T) {
+	casos := []struct {
+		texto  string
+		valor  int64
+		valido bool
+	}{
+		{texto: "42", valor: 42, valido: true},
+		{texto: "010", valor: 10, valido: true},
+		{texto: "0x10", valor: 16, valido: true},
+		{texto: "0xff", valor: 255, valido: true},
+		{texto: "0x", valido: false},
+		{texto: "AX", valido: false},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.texto, func(t *testing.T) {
+			valor, err := Numero(caso.texto)
+			if (err == nil) != caso.valido || (caso.valido && valor != caso.valor) {
+				t.Errorf("Numero(%q) = (%d, %v), se esperaba %d válido=%v", caso.texto, valor, err, caso.valor, caso.valido)
+			}
+		})
+	}
+}
+
+func TestAridad(t *testing.T) {
+	casos := map[string]string{
+		"EXIT":      "ningún parametro",
+		"GOTO":      "1 parametro",
+		"WRITE":     "2 parametros",
+		"INIT_PROC": "2 o 3 parametros",
+	}
+	for cod_op, esperada := range casos {
+		if aridad := Tabla[cod_op].Aridad(); aridad != esperada {
+			t.Errorf("%s: Aridad() = %q, se esperaba %q", cod_op, aridad, esperada)
+		}
+	}
+}
+
+func TestValidar(t *testing.T) {
+	casos := []struct {
+		nombre       string
+		programa     []string
+		opciones     Opciones
+		diagnosticos []Diagnostico
+	}{
+		{
+			nombre:   "programa válido",
+			programa: []string{"SET AX 0x2", "JNZ AX 0", "IO DISCO 10", "EXIT"},
+		},
+		{
+			nombre:       "instrucción desconocida",
+			programa:     []string{"JUMP 0", "EXIT"},
+			diagnosticos: []Diagnostico{{0, "instrucción desconocida: JUMP"}},
+		},
+		{
+			nombre:       "cantidad de parámetros incorrecta",
+			programa:     []string{"SET AX", "INIT_PROC prog", "EXIT"},
+			diagnosticos: []Diagnostico{{0, "SET recibe 2 parametros, se recibieron 1"}, {1, "INIT_PROC recibe 2 o 3 parametros, se recibieron 1"}},
+		},
+		{
+			nombre:   "operandos inválidos",
+			programa: []string{"SET ZX 1", "IO DISCO diez", "GOTO 5"},
+			diagnosticos: []Diagnostico{
+				{0, "SET: operando 1: registro inválido: ZX"},
+				{1, "IO: operando 2: se esperaba un número, se recibió diez"},
+				{2, "GOTO: operando 1: salto a 5 fuera del programa (0 a 2)"},
+			},
+		},
+		{
+			nombre:       "dispositivo de IO desconocido",
+			programa:     []string{"IO RED 10", "IO DISCO 10"},
+			opciones:     Opciones{Dispositivos: []string{"DISCO"}},
+			diagnosticos: []Diagnostico{{0, "IO: operando 1: dispositivo de IO desconocido: RED"}},
+		},
+		{
+			nombre:       "acceso fuera del proceso",
+			programa:     []string{"WRITE 60 hola", "READ 0x3c 4", "READ 62 4"},
+			opciones:     Opciones{TamanioProceso: 64},
+			diagnosticos: []Diagnostico{{2, "READ: acceso a [62, 66) fuera del proceso de 64 bytes"}},
+		},
+		{
+			nombre:   "BRK literal agranda el proceso",
+			programa: []string{"BRK 128", "WRITE 100 hola"},
+			opciones: Opciones{TamanioProceso: 64},
+		},
+		{
+			nombre:       "acceso dentro de un segmento compartido",
+			programa:     []string{"SHM_CREATE buffer 32", "SHM_ATTACH buffer 256", "WRITE 260 hola", "READ 280 16"},
+			opciones:     Opciones{TamanioProceso: 64},
+			diagnosticos: []Diagnostico{{3, "READ: acceso a [280, 296) fuera del proceso de 64 bytes"}},
+		},
+	}
+
+	for _, caso := range casos {
+		t.Run(caso.nombre, func(t *testing.T) {
+			if diagnosticos := Validar(caso.programa, caso.opciones); !reflect.DeepEqual(diagnosticos, caso.diagnosticos) {
+				t.Errorf("Validar() = %v, se esperaba %v", diagnosticos, caso.diagnosticos)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package registros
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Bits del registro FLAGS
+const (
+	FLAG_CERO     uint32 = 1 << 0 // el último resultado fue 0 (o CMP con operandos iguales)
+	FLAG_NEGATIVO uint32 = 1 << 1 // CMP con el primer operando menor que el segundo
+)
+
+// Contexto de ejecución de un proceso. Lo guarda el Kernel en el PCB y viaja con el
+// proceso a la CPU y de vuelta en cada devolución
+type Registros struct {
+	AX    uint8  `json:"ax"`
+	BX    uint8  `json:"bx"`
+	CX    uint8  `json:"cx"`
+	DX    uint8  `json:"dx"`
+	EAX   uint32 `json:"eax"`
+	EBX   uint32 `json:"ebx"`
+	ECX   uint32 `json:"ecx"`
+	EDX   uint32 `json:"edx"`
+	SI    uint32 `json:"si"`
+	DI    uint32 `json:"di"`
+	FLAGS uint32 `json:"flags"`
+}
+
+// Devuelve un puntero al registro de 32 bits o, si es de 8 bits, al de 8
+func (r *Registros) buscar(nombre string) (*uint8, *uint32) {
+	switch strings.ToUpper(nombre) {
+	case "AX":
+		return &r.AX, nil
+	case "BX":
+		return &r.BX, nil
+	case "CX":
+		return &r.CX, nil
+	case "DX":
+		return &r.DX, nil
+	case "EAX":
+		return nil, &r.EAX
+	case "EBX":
+		return nil, &r.EBX
+	case "ECX":
+		return nil, &r.ECX
+	case "EDX":
+		return nil, &r.EDX
+	case "SI":
+		return nil, &r.SI
+	case "DI":
+		return nil, &r.DI
+	}
+	return nil, nil
+}
+
+func EsRegistro(nombre string) bool {
+	var r Registros
+	chico, grande := r.buscar(nombre)
+	return chico != nil || grande != nil
+}
+
+// Tamaño en bytes del registro, 0 si no existe
+func Tamanio(nombre string) int {
+	var r Registros
+	chico, grande := r.buscar(nombre)
+	if chico != nil {
+		return 1
+	} else if grande != nil {
+		return 4
+	}
+	return 0
+}
+
+func (r *Registros) Obtener(nombre string) (uint32, bool) {
+	chico, grande := r.buscar(nombre)
+	if chico != nil {
+		return uint32(*chico), true
+	} else if grande != nil {
+		return *grande, true
+	}
+	return 0, false
+}
+
+// Asigna el valor truncándolo al tamaño del registro
+func (r *Registros) Asignar(nombre string, valor uint32) bool {
+	chico, grande := r.buscar(nombre)
+	if chico != nil {
+		*chico = uint8(valor)
+	} else if grande != nil {
+		*grande = valor
+	} else {
+		return false
+	}
+	return true
+}
+
+// Actualiza FLAG_CERO según el resultado de una operación
+func (r *Registros) ActualizarCero(resultado uint32) {
+	if resultado == 0 {
+		r.FLAGS |= FLAG_CERO
+	} else {
+		r.FLAGS &^= FLAG_CERO
+	}
+}
+
+// CMP: compara dos valores sin modificarlos, solo deja el resultado en FLAGS
+func (r *Registros) Comparar(a uint32, b uint32) {
+	r.ActualizarCero(a - b)
+	if a < b {
+		r.FLAGS |= FLAG_NEGATIVO
+	} else {
+		r.FLAGS &^= FLAG_NEGATIVO
+	}
+}
+
+func (r *Registros) Cero() bool {
+	return r.FLAGS&FLAG_CERO != 0
+}
+
+// Serializar y Deserializar permiten mandar los registros como un único valor de un Paquete
+func (r Registros) Serializar() string {
+	datos, _ := json.Marshal(r)
+	return string(datos)
+}
+
+func Deserializar(datos string) (Registros, error) {
+	var r Registros
+	err := json.Unmarshal([]byte(datos), &r)
+	return r, err
+}